@@ -0,0 +1,83 @@
+// Package audio decodes the PS2's audio formats into PCM and writes WAV files.
+package audio
+
+const (
+	// ADPCMFrameSize is the size of a single SPU ADPCM frame.
+	ADPCMFrameSize = 16
+	// ADPCMSamplesPerFrame is the number of samples a single frame decodes to.
+	ADPCMSamplesPerFrame = 28
+)
+
+// flags stored in the second byte of every ADPCM frame
+const (
+	ADPCMFlagEnd       = 0x01 // last frame of a block, jump to the loop start if ADPCMFlagRepeat is set too
+	ADPCMFlagRepeat    = 0x02
+	ADPCMFlagLoopStart = 0x04
+)
+
+// the SPU's prediction filters, in 1/64ths
+var adpcmFilters = [...][2]int32{
+	{0, 0},
+	{60, 0},
+	{115, -52},
+	{98, -55},
+	{122, -60},
+}
+
+// ADPCMDecoder decodes the SPU's ADPCM format, also known as PS-ADPCM or VAG.
+// It keeps the last two samples around, so every channel needs a decoder of its own.
+type ADPCMDecoder struct {
+	hist1, hist2 int32
+}
+
+// DecodeFrame decodes a 16-byte frame into 28 samples and returns the frame's flags.
+func (d *ADPCMDecoder) DecodeFrame(frame []byte, out []int16) byte {
+	shift := int32(frame[0] & 0x0F)
+	if shift > 12 {
+		// not something the hardware would produce, but seen in the wild
+		shift = 9
+	}
+
+	filter := int(frame[0] >> 4)
+	if filter >= len(adpcmFilters) {
+		filter = 0
+	}
+	f0, f1 := adpcmFilters[filter][0], adpcmFilters[filter][1]
+
+	for i := 0; i < ADPCMSamplesPerFrame; i++ {
+		b := frame[2+i/2]
+		nibble := b & 0x0F
+		if i%2 == 1 {
+			nibble = b >> 4
+		}
+
+		// sign-extend the nibble by putting it into the top bits of an int16
+		sample := int32(int16(uint16(nibble)<<12)) >> shift
+		sample += (d.hist1*f0 + d.hist2*f1 + 32) >> 6
+
+		if sample > 32767 {
+			sample = 32767
+		} else if sample < -32768 {
+			sample = -32768
+		}
+
+		out[i] = int16(sample)
+		d.hist2 = d.hist1
+		d.hist1 = sample
+	}
+
+	return frame[1]
+}
+
+// DecodeADPCM decodes a mono ADPCM stream. A trailing partial frame is ignored.
+func DecodeADPCM(data []byte) []int16 {
+	var d ADPCMDecoder
+
+	numFrames := len(data) / ADPCMFrameSize
+	samples := make([]int16, numFrames*ADPCMSamplesPerFrame)
+	for i := 0; i < numFrames; i++ {
+		d.DecodeFrame(data[i*ADPCMFrameSize:], samples[i*ADPCMSamplesPerFrame:])
+	}
+
+	return samples
+}
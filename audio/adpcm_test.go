@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       byte // filter in the high nibble, shift in the low one
+		flags        byte
+		data         byte // every data byte, low nibble first
+		hist1, hist2 int32
+		want         []int16 // the first few samples
+	}{
+		{"shift 12", 0x0C, 0x00, 0x21, 0, 0, []int16{1, 2, 1, 2}},
+		{"shift 0", 0x00, 0x00, 0x77, 0, 0, []int16{28672, 28672}},
+		{"negative nibble", 0x0C, 0x00, 0xF8, 0, 0, []int16{-8, -1}},
+		{"most negative nibble", 0x00, 0x00, 0x88, 0, 0, []int16{-32768, -32768}},
+		{"shift over 12 is 9", 0x0D, 0x00, 0x11, 0, 0, []int16{8, 8}},
+		{"filter 1", 0x1C, 0x00, 0x00, 64, 0, []int16{60, 56, 53}},
+		{"filter 1 rounds down", 0x1C, 0x00, 0x00, -64, 0, []int16{-60, -56}},
+		{"filter 2", 0x2C, 0x00, 0x00, 100, 50, []int16{139, 169}},
+		{"unknown filter is 0", 0x5C, 0x00, 0x11, 1000, 1000, []int16{1, 1}},
+		{"clamped high", 0x40, 0x00, 0x77, 32767, 0, []int16{32767}},
+		{"clamped low", 0x10, 0x00, 0x88, -32768, 0, []int16{-32768}},
+		{"flags", 0x0C, 0x07, 0x00, 0, 0, []int16{0, 0}},
+	}
+
+	for _, tt := range tests {
+		frame := append([]byte{tt.header, tt.flags}, bytes.Repeat([]byte{tt.data}, ADPCMFrameSize-2)...)
+		d := ADPCMDecoder{hist1: tt.hist1, hist2: tt.hist2}
+		out := make([]int16, ADPCMSamplesPerFrame)
+
+		flags := d.DecodeFrame(frame, out)
+		if flags != tt.flags {
+			t.Errorf("%s: flags = 0x%02X, want 0x%02X", tt.name, flags, tt.flags)
+		}
+
+		if got := out[:len(tt.want)]; !slices.Equal(got, tt.want) {
+			t.Errorf("%s: samples = %v, want %v", tt.name, got, tt.want)
+		}
+
+		if d.hist1 != int32(out[27]) || d.hist2 != int32(out[26]) {
+			t.Errorf("%s: history = %d, %d, want the last two samples %d, %d", tt.name, d.hist1, d.hist2, out[27], out[26])
+		}
+	}
+}
+
+func TestDecodeADPCM(t *testing.T) {
+	frame := append([]byte{0x0C, 0x00}, bytes.Repeat([]byte{0x11}, ADPCMFrameSize-2)...)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantSamples int
+	}{
+		{"empty", nil, 0},
+		{"two frames", bytes.Repeat(frame, 2), 2 * ADPCMSamplesPerFrame},
+		{"partial frame", append(bytes.Repeat(frame, 2), frame[:8]...), 2 * ADPCMSamplesPerFrame},
+	}
+
+	for _, tt := range tests {
+		if got := len(DecodeADPCM(tt.data)); got != tt.wantSamples {
+			t.Errorf("%s: %d samples, want %d", tt.name, got, tt.wantSamples)
+		}
+	}
+}
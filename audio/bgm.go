@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// InterleaveCandidates are the interleave sizes DetectInterleave tries, in bytes.
+var InterleaveCandidates = []int{0x10, 0x80, 0x100, 0x200, 0x400, 0x800, 0x1000, 0x2000, 0x4000, 0x8000}
+
+// detectLength is how much of the stream DetectInterleave decodes for every candidate.
+const detectLength = 0x100000
+
+// DetectInterleave guesses the interleave of multichannel ADPCM data.
+// Each candidate is decoded and the one that produces the smoothest waveform wins:
+// with the wrong interleave, blocks of one channel end up in another and the decoder's
+// prediction jumps wherever that happens, which shows up as clicks.
+func DetectInterleave(data []byte, channels int) (int, error) {
+	if channels <= 1 {
+		return 0, nil
+	}
+
+	best := 0
+	bestRoughness := math.Inf(1)
+	for _, interleave := range InterleaveCandidates {
+		round := channels * interleave
+		if len(data) < 2*round {
+			// a single round decodes the same as every bigger interleave
+			break
+		}
+
+		n := min(len(data), max(detectLength, 8*round))
+		n -= n % round
+
+		samples, err := DecodeInterleavedADPCM(data[:n], channels, interleave)
+		if err != nil {
+			return 0, err
+		}
+
+		r := roughness(samples, channels)
+		if r < bestRoughness {
+			best, bestRoughness = interleave, r
+		}
+	}
+
+	if best == 0 {
+		return 0, fmt.Errorf("%w: %d bytes is too short to detect the interleave", ErrInvalidStream, len(data))
+	}
+
+	return best, nil
+}
+
+// roughness is the mean absolute difference between consecutive samples of the same channel.
+func roughness(samples []int16, channels int) float64 {
+	var sum float64
+	count := 0
+	for i := channels; i < len(samples); i++ {
+		sum += math.Abs(float64(samples[i]) - float64(samples[i-channels]))
+		count++
+	}
+
+	if count == 0 {
+		return math.Inf(1)
+	}
+
+	return sum / float64(count)
+}
@@ -0,0 +1,61 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// levelFrame returns an ADPCM frame that decodes to a constant level without any prediction.
+func levelFrame(nibble byte) []byte {
+	return append([]byte{0x08, 0x00}, bytes.Repeat([]byte{nibble | nibble<<4}, ADPCMFrameSize-2)...)
+}
+
+// multiLevelStream returns rounds of interleaved ADPCM data where every channel holds a level of its own,
+// so mixing up the channels' blocks makes the waveform jump.
+func multiLevelStream(channels, interleave, rounds int) []byte {
+	var data []byte
+	for r := 0; r < rounds; r++ {
+		for c := 0; c < channels; c++ {
+			data = append(data, bytes.Repeat(levelFrame(byte(c+1)), interleave/ADPCMFrameSize)...)
+		}
+	}
+	return data
+}
+
+func TestDetectInterleave(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		channels int
+		want     int
+	}{
+		{"mono", multiLevelStream(1, 0x800, 4), 1, 0},
+		{"stereo 0x10", multiLevelStream(2, 0x10, 0x800), 2, 0x10},
+		{"stereo 0x100", multiLevelStream(2, 0x100, 0x80), 2, 0x100},
+		{"stereo 0x800", multiLevelStream(2, 0x800, 8), 2, 0x800},
+		{"stereo 0x2000", multiLevelStream(2, 0x2000, 4), 2, 0x2000},
+		{"stereo 0x8000, just two rounds", multiLevelStream(2, 0x8000, 2), 2, 0x8000},
+		{"three channels 0x400", multiLevelStream(3, 0x400, 8), 3, 0x400},
+	}
+
+	for _, tt := range tests {
+		got, err := DetectInterleave(tt.data, tt.channels)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: interleave = 0x%X, want 0x%X", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectInterleaveTooShort(t *testing.T) {
+	// a single round of the smallest candidate
+	_, err := DetectInterleave(multiLevelStream(2, 0x10, 1), 2)
+	if !errors.Is(err, ErrInvalidStream) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidStream)
+	}
+}
@@ -0,0 +1,200 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoStreamHeader        = errors.New("no SShd header found")
+	ErrMalformedStreamHeader = errors.New("malformed SShd header")
+	ErrUnsupportedCodec      = errors.New("unsupported codec")
+	ErrInvalidStream         = errors.New("invalid stream parameters")
+)
+
+// codecs used in SShd headers
+const (
+	CodecPCM16 = 0x01 // 16-bit little-endian PCM
+	CodecADPCM = 0x10 // SPU ADPCM
+)
+
+// StreamHeader is the SShd header found at the start of audio streams, followed by an SSbd header and the data.
+//
+//	0x00 "SShd"
+//	0x04 header size (0x18)
+//	0x08 codec
+//	0x0C sample rate
+//	0x10 channels
+//	0x14 interleave
+//	0x18 loop start
+//	0x1C loop end
+//	0x20 "SSbd"
+//	0x24 data size
+//	0x28 data
+type StreamHeader struct {
+	Codec      uint32
+	SampleRate uint32
+	Channels   uint32
+	Interleave uint32
+	LoopStart  uint32
+	LoopEnd    uint32
+	DataSize   uint32
+}
+
+// FindStreamHeader looks for an SShd header in data.
+// It returns the header along with the offsets of the header itself and of the data following it.
+// ErrNoStreamHeader means there's no SShd in data at all, ErrMalformedStreamHeader that there is, but it can't be read.
+func FindStreamHeader(data []byte) (StreamHeader, int, int, error) {
+	start := bytes.Index(data, []byte("SShd"))
+	if start < 0 {
+		return StreamHeader{}, 0, 0, ErrNoStreamHeader
+	}
+
+	if len(data) < start+8 {
+		return StreamHeader{}, 0, 0, fmt.Errorf("%w: it's truncated", ErrMalformedStreamHeader)
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(data[start+4:]))
+	fieldsEnd := start + 8 + headerSize
+	if headerSize < 0x18 || len(data) < fieldsEnd+8 {
+		return StreamHeader{}, 0, 0, fmt.Errorf("%w: it's truncated", ErrMalformedStreamHeader)
+	}
+
+	fields := data[start+8:]
+	h := StreamHeader{
+		Codec:      binary.LittleEndian.Uint32(fields[0x00:]),
+		SampleRate: binary.LittleEndian.Uint32(fields[0x04:]),
+		Channels:   binary.LittleEndian.Uint32(fields[0x08:]),
+		Interleave: binary.LittleEndian.Uint32(fields[0x0C:]),
+		LoopStart:  binary.LittleEndian.Uint32(fields[0x10:]),
+		LoopEnd:    binary.LittleEndian.Uint32(fields[0x14:]),
+	}
+
+	if !bytes.Equal(data[fieldsEnd:fieldsEnd+4], []byte("SSbd")) {
+		return StreamHeader{}, 0, 0, fmt.Errorf("%w: it isn't followed by SSbd", ErrMalformedStreamHeader)
+	}
+	h.DataSize = binary.LittleEndian.Uint32(data[fieldsEnd+4:])
+
+	return h, start, fieldsEnd + 8, nil
+}
+
+// Decode decodes interleaved stream data described by the header.
+func (h StreamHeader) Decode(data []byte) (*WAV, error) {
+	if h.Channels == 0 || h.SampleRate == 0 {
+		return nil, fmt.Errorf("%w: %d channels at %d Hz", ErrInvalidStream, h.Channels, h.SampleRate)
+	}
+
+	if h.DataSize != 0 && int(h.DataSize) < len(data) {
+		data = data[:h.DataSize]
+	}
+
+	var samples []int16
+	var err error
+
+	switch h.Codec {
+	case CodecADPCM:
+		samples, err = DecodeInterleavedADPCM(data, int(h.Channels), int(h.Interleave))
+	case CodecPCM16:
+		samples, err = DecodeInterleavedPCM16(data, int(h.Channels), int(h.Interleave))
+	default:
+		return nil, fmt.Errorf("%w: 0x%X", ErrUnsupportedCodec, h.Codec)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &WAV{
+		SampleRate: int(h.SampleRate),
+		Channels:   int(h.Channels),
+		Samples:    samples,
+	}, nil
+}
+
+// deinterleave splits data into one slice per channel.
+// Every channel gets interleave bytes in turn, a trailing partial block is split evenly.
+func deinterleave(data []byte, channels, interleave int) ([][]byte, error) {
+	if channels <= 0 {
+		return nil, fmt.Errorf("%w: %d channels", ErrInvalidStream, channels)
+	}
+
+	if channels == 1 {
+		return [][]byte{data}, nil
+	}
+
+	if interleave <= 0 {
+		return nil, fmt.Errorf("%w: interleave of %d bytes", ErrInvalidStream, interleave)
+	}
+
+	split := make([][]byte, channels)
+	for pos := 0; pos < len(data); {
+		blockSize := interleave
+		if remaining := len(data) - pos; remaining < channels*interleave {
+			blockSize = remaining / channels
+			if blockSize == 0 {
+				break
+			}
+		}
+
+		for c := 0; c < channels; c++ {
+			split[c] = append(split[c], data[pos:pos+blockSize]...)
+			pos += blockSize
+		}
+	}
+
+	return split, nil
+}
+
+// interleaveSamples combines per-channel samples into interleaved samples, cutting off at the shortest channel.
+func interleaveSamples(channelSamples [][]int16) []int16 {
+	length := len(channelSamples[0])
+	for _, s := range channelSamples {
+		length = min(length, len(s))
+	}
+
+	channels := len(channelSamples)
+	samples := make([]int16, length*channels)
+	for i := 0; i < length; i++ {
+		for c, s := range channelSamples {
+			samples[i*channels+c] = s[i]
+		}
+	}
+
+	return samples
+}
+
+// DecodeInterleavedADPCM decodes ADPCM data with interleave bytes per channel in turn into interleaved samples.
+func DecodeInterleavedADPCM(data []byte, channels, interleave int) ([]int16, error) {
+	split, err := deinterleave(data, channels, interleave)
+	if err != nil {
+		return nil, err
+	}
+
+	channelSamples := make([][]int16, channels)
+	for c, channelData := range split {
+		channelSamples[c] = DecodeADPCM(channelData)
+	}
+
+	return interleaveSamples(channelSamples), nil
+}
+
+// DecodeInterleavedPCM16 converts 16-bit little-endian PCM data with interleave bytes per channel in turn into interleaved samples.
+func DecodeInterleavedPCM16(data []byte, channels, interleave int) ([]int16, error) {
+	split, err := deinterleave(data, channels, interleave)
+	if err != nil {
+		return nil, err
+	}
+
+	channelSamples := make([][]int16, channels)
+	for c, channelData := range split {
+		s := make([]int16, len(channelData)/2)
+		for i := range s {
+			s[i] = int16(binary.LittleEndian.Uint16(channelData[i*2:]))
+		}
+		channelSamples[c] = s
+	}
+
+	return interleaveSamples(channelSamples), nil
+}
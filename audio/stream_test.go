@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// count returns the bytes 0 to n-1.
+func count(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestDeinterleave(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		channels   int
+		interleave int
+		want       [][]byte
+	}{
+		{"mono", count(5), 1, 0, [][]byte{{0, 1, 2, 3, 4}}},
+		{"whole rounds", count(8), 2, 2, [][]byte{{0, 1, 4, 5}, {2, 3, 6, 7}}},
+		{"partial round", count(10), 2, 2, [][]byte{{0, 1, 4, 5, 8}, {2, 3, 6, 7, 9}}},
+		{"odd partial round", count(11), 2, 2, [][]byte{{0, 1, 4, 5, 8}, {2, 3, 6, 7, 9}}},
+		{"partial round too short to split", count(13), 3, 4, [][]byte{{0, 1, 2, 3}, {4, 5, 6, 7}, {8, 9, 10, 11}}},
+		{"three channel partial round", count(20), 3, 4, [][]byte{{0, 1, 2, 3, 12, 13}, {4, 5, 6, 7, 14, 15}, {8, 9, 10, 11, 16, 17}}},
+	}
+
+	for _, tt := range tests {
+		got, err := deinterleave(tt.data, tt.channels, tt.interleave)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d channels, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+
+		for c := range got {
+			if !bytes.Equal(got[c], tt.want[c]) {
+				t.Errorf("%s: channel %d = %v, want %v", tt.name, c, got[c], tt.want[c])
+			}
+		}
+	}
+}
+
+func TestDeinterleaveErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		channels   int
+		interleave int
+	}{
+		{"no channels", 0, 0x800},
+		{"no interleave", 2, 0},
+		{"negative interleave", 2, -1},
+	}
+
+	for _, tt := range tests {
+		_, err := deinterleave(count(16), tt.channels, tt.interleave)
+		if !errors.Is(err, ErrInvalidStream) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, ErrInvalidStream)
+		}
+	}
+}
+
+func TestFindStreamHeader(t *testing.T) {
+	valid := testStream(CodecADPCM, 2, 0x800, 0x100, 0x100)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"valid", valid, nil},
+		{"no SShd", count(0x40), ErrNoStreamHeader},
+		{"no header size", valid[:10], ErrMalformedStreamHeader},
+		{"truncated fields", valid[:20], ErrMalformedStreamHeader},
+		{"no SSbd", bytes.Replace(valid, []byte("SSbd"), []byte("SSxx"), 1), ErrMalformedStreamHeader},
+	}
+
+	for _, tt := range tests {
+		h, start, dataStart, err := FindStreamHeader(tt.data)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		want := StreamHeader{Codec: CodecADPCM, SampleRate: 22050, Channels: 2, Interleave: 0x800, DataSize: 0x100}
+		if h != want || start != 4 || dataStart != 0x2C {
+			t.Errorf("%s: got %+v at 0x%X with data at 0x%X, want %+v at 0x4 with data at 0x2C", tt.name, h, start, dataStart, want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxHeaderSearch is how much data StreamWriter buffers while looking for the SShd header.
+const maxHeaderSearch = 0x10000
+
+// StreamWriter decodes a stream with an SShd header into a WAV file as it's written to it,
+// so streams of any length can be decoded without holding them in memory.
+// Write never fails, problems with the stream or the WAV file are reported by Close, which also finishes the WAV file.
+type StreamWriter struct {
+	w  io.WriteSeeker
+	bw *bufio.Writer
+
+	header    StreamHeader
+	started   bool
+	buf       []byte // data that hasn't been decoded yet, everything written so far until the header is found
+	remaining int64  // data bytes left according to the header, -1 if it doesn't say
+	decoders  []ADPCMDecoder
+	dataSize  int64 // bytes of samples written so far
+
+	err error
+}
+
+// NewStreamWriter returns a StreamWriter that writes the decoded audio to w.
+func NewStreamWriter(w io.WriteSeeker) *StreamWriter {
+	return &StreamWriter{
+		w:  w,
+		bw: bufio.NewWriter(w),
+	}
+}
+
+// Header returns the stream's header. It's only valid once the header has been written.
+func (s *StreamWriter) Header() StreamHeader {
+	return s.header
+}
+
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return len(p), nil
+	}
+
+	if !s.started {
+		s.buf = append(s.buf, p...)
+		s.err = s.start()
+		return len(p), nil
+	}
+
+	s.buf = append(s.buf, s.limit(p)...)
+	s.err = s.decode(false)
+	return len(p), nil
+}
+
+// start parses the header once enough data has been buffered and writes the WAV header.
+func (s *StreamWriter) start() error {
+	start := bytes.Index(s.buf, []byte("SShd"))
+	if start < 0 {
+		if len(s.buf) > maxHeaderSearch {
+			return ErrNoStreamHeader
+		}
+		return nil
+	}
+
+	if len(s.buf) < start+8 {
+		return nil
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(s.buf[start+4:]))
+	if headerSize <= maxHeaderSearch && len(s.buf) < start+8+headerSize+8 {
+		// wait for the SSbd header
+		return nil
+	}
+
+	h, _, dataStart, err := FindStreamHeader(s.buf)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case h.Channels == 0 || h.SampleRate == 0:
+		return fmt.Errorf("%w: %d channels at %d Hz", ErrInvalidStream, h.Channels, h.SampleRate)
+	case h.Codec != CodecADPCM && h.Codec != CodecPCM16:
+		return fmt.Errorf("%w: 0x%X", ErrUnsupportedCodec, h.Codec)
+	case h.Channels > 1 && h.Interleave == 0:
+		return fmt.Errorf("%w: interleave of %d bytes", ErrInvalidStream, h.Interleave)
+	}
+
+	s.header = h
+	s.started = true
+	s.decoders = make([]ADPCMDecoder, h.Channels)
+	s.remaining = -1
+	if h.DataSize != 0 {
+		s.remaining = int64(h.DataSize)
+	}
+
+	data := s.buf[dataStart:]
+	s.buf = nil
+	s.buf = append(s.buf, s.limit(data)...)
+
+	// the sizes are filled in by Close
+	err = s.writeHeaders(s.bw, 0)
+	if err != nil {
+		return err
+	}
+
+	return s.decode(false)
+}
+
+// writeHeaders writes the WAV header and the data chunk's header for dataSize bytes of samples.
+func (s *StreamWriter) writeHeaders(w io.Writer, dataSize uint32) error {
+	header := newWAVHeader(int(s.header.SampleRate), int(s.header.Channels), 36+dataSize)
+	err := binary.Write(w, binary.LittleEndian, &header)
+	if err != nil {
+		return err
+	}
+
+	dataHeader := newDataChunkHeader(dataSize)
+	return binary.Write(w, binary.LittleEndian, &dataHeader)
+}
+
+// limit cuts p off where the data ends according to the header.
+func (s *StreamWriter) limit(p []byte) []byte {
+	if s.remaining < 0 {
+		return p
+	}
+
+	n := min(int64(len(p)), s.remaining)
+	s.remaining -= n
+	return p[:n]
+}
+
+// decode decodes every complete round of interleaved blocks in the buffer.
+// If final is set, a trailing partial round is split evenly between the channels, like DecodeInterleavedADPCM does.
+func (s *StreamWriter) decode(final bool) error {
+	channels := int(s.header.Channels)
+
+	blockSize := int(s.header.Interleave)
+	if channels == 1 {
+		// mono data isn't interleaved, decode as much as there is
+		unit := ADPCMFrameSize
+		if s.header.Codec == CodecPCM16 {
+			unit = 2
+		}
+		blockSize = len(s.buf) - len(s.buf)%unit
+	}
+
+	pos := 0
+	for blockSize > 0 && len(s.buf)-pos >= channels*blockSize {
+		err := s.decodeRound(s.buf[pos:], blockSize)
+		if err != nil {
+			return err
+		}
+		pos += channels * blockSize
+	}
+
+	if final {
+		if blockSize = (len(s.buf) - pos) / channels; blockSize > 0 {
+			err := s.decodeRound(s.buf[pos:], blockSize)
+			if err != nil {
+				return err
+			}
+		}
+		pos = len(s.buf)
+	}
+
+	s.buf = append(s.buf[:0], s.buf[pos:]...)
+	return nil
+}
+
+// decodeRound decodes one block of blockSize bytes for every channel and writes the interleaved samples.
+func (s *StreamWriter) decodeRound(data []byte, blockSize int) error {
+	channelSamples := make([][]int16, len(s.decoders))
+	for c := range channelSamples {
+		block := data[c*blockSize : (c+1)*blockSize]
+
+		if s.header.Codec == CodecPCM16 {
+			samples := make([]int16, len(block)/2)
+			for i := range samples {
+				samples[i] = int16(binary.LittleEndian.Uint16(block[i*2:]))
+			}
+			channelSamples[c] = samples
+			continue
+		}
+
+		numFrames := len(block) / ADPCMFrameSize
+		samples := make([]int16, numFrames*ADPCMSamplesPerFrame)
+		for i := 0; i < numFrames; i++ {
+			s.decoders[c].DecodeFrame(block[i*ADPCMFrameSize:], samples[i*ADPCMSamplesPerFrame:])
+		}
+		channelSamples[c] = samples
+	}
+
+	samples := interleaveSamples(channelSamples)
+	s.dataSize += int64(len(samples) * 2)
+	return binary.Write(s.bw, binary.LittleEndian, samples)
+}
+
+// Close decodes what's left and fills in the sizes in the WAV header. It doesn't close the underlying writer.
+func (s *StreamWriter) Close() error {
+	if s.err == nil && !s.started {
+		_, _, _, s.err = FindStreamHeader(s.buf)
+		if s.err == nil {
+			s.err = fmt.Errorf("%w: the stream ended within it", ErrMalformedStreamHeader)
+		}
+	}
+
+	if s.err == nil {
+		s.err = s.decode(true)
+	}
+
+	if s.err != nil {
+		return s.err
+	}
+
+	err := s.bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if s.dataSize > math.MaxUint32-36 {
+		return fmt.Errorf("%w: %d bytes of samples don't fit into a WAV file", ErrInvalidStream, s.dataSize)
+	}
+
+	_, err = s.w.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	err = s.writeHeaders(s.w, uint32(s.dataSize))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.w.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,146 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// seekBuffer is an in-memory io.WriteSeeker.
+type seekBuffer struct {
+	data []byte
+	pos  int
+}
+
+func (b *seekBuffer) Write(p []byte) (int, error) {
+	if end := b.pos + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	n := copy(b.data[b.pos:], p)
+	b.pos += n
+	return n, nil
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		b.pos = int(offset)
+	case io.SeekCurrent:
+		b.pos += int(offset)
+	case io.SeekEnd:
+		b.pos = len(b.data) + int(offset)
+	}
+	return int64(b.pos), nil
+}
+
+// testStream returns an SShd stream with the given header fields, followed by dataLen bytes of made-up data and some trailing garbage.
+func testStream(codec, channels, interleave, dataSize uint32, dataLen int) []byte {
+	var b bytes.Buffer
+	b.WriteString("junk")
+	b.WriteString("SShd")
+	_ = binary.Write(&b, binary.LittleEndian, []uint32{0x18, codec, 22050, channels, interleave, 0, 0})
+	b.WriteString("SSbd")
+	_ = binary.Write(&b, binary.LittleEndian, dataSize)
+
+	for i := 0; i < dataLen; i++ {
+		v := byte(i*7 + i/16)
+		if codec == CodecADPCM && i%ADPCMFrameSize == 0 {
+			v = byte(i/ADPCMFrameSize%5)<<4 | byte(i%12) // filter and shift
+		}
+		b.WriteByte(v)
+	}
+
+	return b.Bytes()
+}
+
+func TestStreamWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		codec      uint32
+		channels   uint32
+		interleave uint32
+		dataSize   uint32
+		dataLen    int
+	}{
+		{"mono ADPCM", CodecADPCM, 1, 0, 0, 0x1000},
+		{"mono ADPCM with partial frame", CodecADPCM, 1, 0, 0, 0x1008},
+		{"stereo ADPCM", CodecADPCM, 2, 0x800, 0, 0x4000},
+		{"stereo ADPCM with partial round", CodecADPCM, 2, 0x800, 0, 0x4000 + 0x460},
+		{"stereo ADPCM cut off by DataSize", CodecADPCM, 2, 0x100, 0x900, 0x1000},
+		{"three channel PCM16", CodecPCM16, 3, 0x40, 0, 0x3F0},
+	}
+
+	for _, tt := range tests {
+		stream := testStream(tt.codec, tt.channels, tt.interleave, tt.dataSize, tt.dataLen)
+
+		h, _, dataStart, err := FindStreamHeader(stream)
+		if err != nil {
+			t.Fatalf("%s: FindStreamHeader: %v", tt.name, err)
+		}
+
+		wav, err := h.Decode(stream[dataStart:])
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tt.name, err)
+		}
+
+		var want bytes.Buffer
+		_, err = wav.WriteTo(&want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// odd write sizes, so the header and the blocks are split up
+		for _, chunkSize := range []int{1, 7, 0x333, len(stream)} {
+			var got seekBuffer
+			sw := NewStreamWriter(&got)
+			for pos := 0; pos < len(stream); pos += chunkSize {
+				_, _ = sw.Write(stream[pos:min(pos+chunkSize, len(stream))])
+			}
+
+			err := sw.Close()
+			if err != nil {
+				t.Errorf("%s, writes of %d bytes: Close: %v", tt.name, chunkSize, err)
+				continue
+			}
+
+			if sw.Header() != h {
+				t.Errorf("%s, writes of %d bytes: Header() = %+v, want %+v", tt.name, chunkSize, sw.Header(), h)
+			}
+
+			if !bytes.Equal(got.data, want.Bytes()) {
+				t.Errorf("%s, writes of %d bytes: got %d bytes of WAV, want the %d bytes Decode gives", tt.name, chunkSize, len(got.data), want.Len())
+			}
+		}
+	}
+}
+
+func TestStreamWriterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []byte
+		want   error
+	}{
+		{"empty", nil, ErrNoStreamHeader},
+		{"no header", bytes.Repeat([]byte{1}, maxHeaderSearch+1), ErrNoStreamHeader},
+		{"truncated header", testStream(CodecADPCM, 2, 0x800, 0, 0)[:20], ErrMalformedStreamHeader},
+		{"no channels", testStream(CodecADPCM, 0, 0x800, 0, 0x100), ErrInvalidStream},
+		{"no interleave", testStream(CodecADPCM, 2, 0, 0, 0x100), ErrInvalidStream},
+		{"unknown codec", testStream(0x42, 2, 0x800, 0, 0x100), ErrUnsupportedCodec},
+	}
+
+	for _, tt := range tests {
+		var out seekBuffer
+		sw := NewStreamWriter(&out)
+		n, err := sw.Write(tt.stream)
+		if n != len(tt.stream) || err != nil {
+			t.Errorf("%s: Write = %d, %v, want %d, nil", tt.name, n, err, len(tt.stream))
+		}
+
+		err = sw.Close()
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Close = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// VAGHeaderSize is the size of a VAGp header, the ADPCM data starts right after it.
+const VAGHeaderSize = 0x30
+
+var (
+	ErrNotVAG = errors.New("not a VAGp file")
+)
+
+// VAGHeader is the header of a VAGp file. All of its numbers are big-endian.
+//
+//	0x00 "VAGp"
+//	0x04 version
+//	0x0C data size
+//	0x10 sample rate
+//	0x20 name
+//	0x30 data
+type VAGHeader struct {
+	Version    uint32
+	DataSize   uint32
+	SampleRate uint32
+	Name       string
+}
+
+// ReadVAGHeader parses the VAGp header at the start of data.
+func ReadVAGHeader(data []byte) (VAGHeader, error) {
+	if len(data) < VAGHeaderSize || !bytes.HasPrefix(data, []byte("VAGp")) {
+		return VAGHeader{}, ErrNotVAG
+	}
+
+	name, _, _ := bytes.Cut(data[0x20:0x30], []byte{0})
+
+	return VAGHeader{
+		Version:    binary.BigEndian.Uint32(data[0x04:]),
+		DataSize:   binary.BigEndian.Uint32(data[0x0C:]),
+		SampleRate: binary.BigEndian.Uint32(data[0x10:]),
+		Name:       string(name),
+	}, nil
+}
+
+// DecodeVAG decodes a VAGp file. If sampleRate isn't zero, it overrides the one in the header.
+func DecodeVAG(data []byte, sampleRate int) (*WAV, error) {
+	h, err := ReadVAGHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if sampleRate == 0 {
+		sampleRate = int(h.SampleRate)
+	}
+
+	if sampleRate == 0 {
+		return nil, fmt.Errorf("%w: no sample rate", ErrInvalidStream)
+	}
+
+	adpcm := data[VAGHeaderSize:]
+	if h.DataSize != 0 && int(h.DataSize) < len(adpcm) {
+		adpcm = adpcm[:h.DataSize]
+	}
+
+	return DecodeSPU(adpcm, sampleRate), nil
+}
+
+// DecodeSPU decodes mono SPU ADPCM data without a header, the way the SPU would play it:
+// decoding stops at the first frame with the end flag, and if that frame also has the repeat flag,
+// the samples from the frame with the loop start flag up to there become the WAV's loop.
+func DecodeSPU(data []byte, sampleRate int) *WAV {
+	var d ADPCMDecoder
+
+	wav := &WAV{
+		SampleRate: sampleRate,
+		Channels:   1,
+	}
+
+	frame := make([]int16, ADPCMSamplesPerFrame)
+	loopStart := -1
+	for pos := 0; pos+ADPCMFrameSize <= len(data); pos += ADPCMFrameSize {
+		if data[pos+1] == ADPCMFlagEnd|ADPCMFlagRepeat|ADPCMFlagLoopStart {
+			// a frame with every flag set marks the end of the data, it's not meant to be played
+			break
+		}
+
+		flags := d.DecodeFrame(data[pos:], frame)
+		if flags&ADPCMFlagLoopStart != 0 && loopStart < 0 {
+			loopStart = len(wav.Samples)
+		}
+
+		wav.Samples = append(wav.Samples, frame...)
+
+		if flags&ADPCMFlagEnd != 0 {
+			if flags&ADPCMFlagRepeat != 0 && loopStart >= 0 {
+				wav.Loop = &Loop{Start: loopStart, End: len(wav.Samples)}
+			}
+			break
+		}
+	}
+
+	return wav
+}
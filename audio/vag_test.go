@@ -0,0 +1,94 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// spuFrames returns silent ADPCM frames with the given flags.
+func spuFrames(flags ...byte) []byte {
+	var data []byte
+	for _, f := range flags {
+		frame := make([]byte, ADPCMFrameSize)
+		frame[1] = f
+		data = append(data, frame...)
+	}
+	return data
+}
+
+func TestDecodeSPU(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		wantSamples int
+		wantLoop    *Loop
+	}{
+		{"no flags", spuFrames(0, 0, 0), 84, nil},
+		{"loop", spuFrames(0, 0x04, 0, 0x03, 0x07), 112, &Loop{Start: 28, End: 112}},
+		{"loop over everything", spuFrames(0x04, 0, 0x03), 84, &Loop{Start: 0, End: 84}},
+		{"first loop start wins", spuFrames(0x04, 0x04, 0x03), 84, &Loop{Start: 0, End: 84}},
+		{"end without repeat", spuFrames(0, 0x04, 0x01, 0), 84, nil},
+		{"repeat without loop start", spuFrames(0, 0, 0x03, 0), 84, nil},
+		{"every flag ends it", spuFrames(0, 0x07, 0), 28, nil},
+		{"partial frame", append(spuFrames(0, 0), 0, 0, 0, 0), 56, nil},
+	}
+
+	for _, tt := range tests {
+		wav := DecodeSPU(tt.data, 22050)
+		if len(wav.Samples) != tt.wantSamples {
+			t.Errorf("%s: %d samples, want %d", tt.name, len(wav.Samples), tt.wantSamples)
+		}
+
+		switch {
+		case wav.Loop == nil && tt.wantLoop == nil:
+		case wav.Loop == nil || tt.wantLoop == nil || *wav.Loop != *tt.wantLoop:
+			t.Errorf("%s: loop = %v, want %v", tt.name, wav.Loop, tt.wantLoop)
+		}
+	}
+}
+
+// vagFile returns a VAGp file with the given header fields and ADPCM data.
+func vagFile(magic string, dataSize, sampleRate uint32, data []byte) []byte {
+	header := make([]byte, VAGHeaderSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[0x04:], 0x20)
+	binary.BigEndian.PutUint32(header[0x0C:], dataSize)
+	binary.BigEndian.PutUint32(header[0x10:], sampleRate)
+	copy(header[0x20:], "test")
+	return append(header, data...)
+}
+
+func TestDecodeVAG(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           []byte
+		sampleRate     int
+		wantErr        error
+		wantSampleRate int
+		wantSamples    int
+	}{
+		{"header sample rate", vagFile("VAGp", 0, 22050, spuFrames(0, 0)), 0, nil, 22050, 56},
+		{"overridden sample rate", vagFile("VAGp", 0, 22050, spuFrames(0, 0)), 44100, nil, 44100, 56},
+		{"cut off by data size", vagFile("VAGp", 16, 22050, spuFrames(0, 0)), 0, nil, 22050, 28},
+		{"no sample rate", vagFile("VAGp", 0, 0, spuFrames(0, 0)), 0, ErrInvalidStream, 0, 0},
+		{"interleaved", vagFile("VAGi", 0, 22050, spuFrames(0, 0)), 0, ErrNotVAG, 0, 0},
+		{"too short", []byte("VAGp"), 0, ErrNotVAG, 0, 0},
+	}
+
+	for _, tt := range tests {
+		wav, err := DecodeVAG(tt.data, tt.sampleRate)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if wav.SampleRate != tt.wantSampleRate || len(wav.Samples) != tt.wantSamples {
+			t.Errorf("%s: %d samples at %d Hz, want %d at %d Hz", tt.name, len(wav.Samples), wav.SampleRate, tt.wantSamples, tt.wantSampleRate)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Loop is a section of audio that's meant to be repeated, in sample frames. End is exclusive.
+type Loop struct {
+	Start int
+	End   int
+}
+
+// WAV is 16-bit PCM audio that can be written as a RIFF WAVE file.
+type WAV struct {
+	SampleRate int
+	Channels   int
+	Samples    []int16 // interleaved if there's more than one channel
+
+	// Loop is written as a smpl chunk, if it's set
+	Loop *Loop
+}
+
+// smplChunk is a smpl chunk with a single loop.
+type smplChunk struct {
+	ID                [4]byte
+	Size              uint32
+	Manufacturer      uint32
+	Product           uint32
+	SamplePeriod      uint32
+	MIDIUnityNote     uint32
+	MIDIPitchFraction uint32
+	SMPTEFormat       uint32
+	SMPTEOffset       uint32
+	NumSampleLoops    uint32
+	SamplerData       uint32
+
+	CuePointID uint32
+	Type       uint32
+	Start      uint32
+	End        uint32 // inclusive
+	Fraction   uint32
+	PlayCount  uint32
+}
+
+// wavHeader is the RIFF header and fmt chunk of a 16-bit PCM WAV file.
+type wavHeader struct {
+	RIFF          [4]byte
+	RIFFSize      uint32
+	WAVE          [4]byte
+	FMT           [4]byte
+	FMTSize       uint32
+	Format        uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+func newWAVHeader(sampleRate, channels int, riffSize uint32) wavHeader {
+	const bitsPerSample = 16
+	blockAlign := uint16(channels * bitsPerSample / 8)
+
+	return wavHeader{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		RIFFSize:      riffSize,
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		FMT:           [4]byte{'f', 'm', 't', ' '},
+		FMTSize:       16,
+		Format:        1, // PCM
+		Channels:      uint16(channels),
+		SampleRate:    uint32(sampleRate),
+		ByteRate:      uint32(sampleRate) * uint32(blockAlign),
+		BlockAlign:    blockAlign,
+		BitsPerSample: bitsPerSample,
+	}
+}
+
+// dataChunkHeader is the start of the data chunk, the samples follow.
+type dataChunkHeader struct {
+	DATA     [4]byte
+	DataSize uint32
+}
+
+func newDataChunkHeader(dataSize uint32) dataChunkHeader {
+	return dataChunkHeader{
+		DATA:     [4]byte{'d', 'a', 't', 'a'},
+		DataSize: dataSize,
+	}
+}
+
+// WriteTo writes the audio as a WAV file.
+func (wav *WAV) WriteTo(w io.Writer) (int64, error) {
+	dataSize := uint32(len(wav.Samples) * 2)
+
+	var smpl *smplChunk
+	if wav.Loop != nil && wav.Loop.End > wav.Loop.Start && wav.SampleRate > 0 {
+		smpl = &smplChunk{
+			ID:             [4]byte{'s', 'm', 'p', 'l'},
+			SamplePeriod:   uint32(1_000_000_000 / wav.SampleRate),
+			MIDIUnityNote:  60,
+			NumSampleLoops: 1,
+			Type:           0, // forward
+			Start:          uint32(wav.Loop.Start),
+			End:            uint32(wav.Loop.End - 1),
+			PlayCount:      0, // forever
+		}
+		smpl.Size = uint32(binary.Size(smpl)) - 8
+	}
+
+	riffSize := 36 + dataSize
+	if smpl != nil {
+		riffSize += uint32(binary.Size(smpl))
+	}
+
+	header := newWAVHeader(wav.SampleRate, wav.Channels, riffSize)
+	data := newDataChunkHeader(dataSize)
+
+	chunks := []any{&header}
+	if smpl != nil {
+		chunks = append(chunks, smpl)
+	}
+	chunks = append(chunks, &data, wav.Samples)
+
+	var written int64
+	for _, chunk := range chunks {
+		err := binary.Write(w, binary.LittleEndian, chunk)
+		if err != nil {
+			return written, err
+		}
+		written += int64(binary.Size(chunk))
+	}
+
+	return written, nil
+}
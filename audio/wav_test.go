@@ -0,0 +1,107 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// riffChunks splits a RIFF WAVE file into its chunks, failing if the sizes don't add up.
+func riffChunks(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		t.Fatalf("not a RIFF WAVE file: % X", data[:min(len(data), 12)])
+	}
+
+	if riffSize := binary.LittleEndian.Uint32(data[4:]); int(riffSize) != len(data)-8 {
+		t.Errorf("RIFF size = %d, want %d", riffSize, len(data)-8)
+	}
+
+	chunks := map[string][]byte{}
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			t.Fatalf("chunk header at 0x%X is truncated", pos)
+		}
+
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if pos+8+size > len(data) {
+			t.Fatalf("%s chunk at 0x%X is %d bytes long, but only %d are left", id, pos, size, len(data)-pos-8)
+		}
+
+		chunks[id] = data[pos+8 : pos+8+size]
+		pos += 8 + size
+	}
+
+	return chunks
+}
+
+func TestWAVWriteTo(t *testing.T) {
+	tests := []struct {
+		name         string
+		wav          WAV
+		wantSmpl     bool
+		wantLoopEnds [2]uint32 // start and inclusive end in the smpl chunk
+	}{
+		{"stereo", WAV{SampleRate: 48000, Channels: 2, Samples: make([]int16, 8)}, false, [2]uint32{}},
+		{"mono with loop", WAV{SampleRate: 22050, Channels: 1, Samples: make([]int16, 112), Loop: &Loop{Start: 28, End: 112}}, true, [2]uint32{28, 111}},
+		{"stereo with loop", WAV{SampleRate: 44100, Channels: 2, Samples: make([]int16, 10), Loop: &Loop{Start: 0, End: 5}}, true, [2]uint32{0, 4}},
+		{"empty loop", WAV{SampleRate: 22050, Channels: 1, Samples: make([]int16, 10), Loop: &Loop{Start: 5, End: 5}}, false, [2]uint32{}},
+		{"loop without sample rate", WAV{Channels: 1, Samples: make([]int16, 10), Loop: &Loop{Start: 0, End: 5}}, false, [2]uint32{}},
+		{"no samples", WAV{SampleRate: 22050, Channels: 1}, false, [2]uint32{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := tt.wav.WriteTo(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n != int64(buf.Len()) {
+				t.Errorf("WriteTo = %d, but wrote %d bytes", n, buf.Len())
+			}
+
+			chunks := riffChunks(t, buf.Bytes())
+
+			fmtChunk := chunks["fmt "]
+			if len(fmtChunk) != 16 {
+				t.Fatalf("fmt chunk is %d bytes, want 16", len(fmtChunk))
+			}
+
+			blockAlign := uint32(tt.wav.Channels * 2)
+			if byteRate := binary.LittleEndian.Uint32(fmtChunk[8:]); byteRate != uint32(tt.wav.SampleRate)*blockAlign {
+				t.Errorf("byte rate = %d, want %d", byteRate, uint32(tt.wav.SampleRate)*blockAlign)
+			}
+
+			if data := chunks["data"]; len(data) != len(tt.wav.Samples)*2 {
+				t.Errorf("data chunk is %d bytes, want %d", len(data), len(tt.wav.Samples)*2)
+			}
+
+			smpl, ok := chunks["smpl"]
+			if ok != tt.wantSmpl {
+				t.Fatalf("has smpl chunk = %v, want %v", ok, tt.wantSmpl)
+			}
+
+			if !ok {
+				return
+			}
+
+			// 9 fields, then a single loop of 6
+			if len(smpl) != 60 {
+				t.Fatalf("smpl chunk is %d bytes, want 60", len(smpl))
+			}
+
+			if numLoops := binary.LittleEndian.Uint32(smpl[28:]); numLoops != 1 {
+				t.Errorf("smpl chunk has %d loops, want 1", numLoops)
+			}
+
+			start, end := binary.LittleEndian.Uint32(smpl[44:]), binary.LittleEndian.Uint32(smpl[48:])
+			if [2]uint32{start, end} != tt.wantLoopEnds {
+				t.Errorf("loop = %d-%d, want %d-%d", start, end, tt.wantLoopEnds[0], tt.wantLoopEnds[1])
+			}
+		})
+	}
+}
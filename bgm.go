@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sh2unpack/audio"
+)
+
+// used for headerless streams unless the flags say otherwise
+const (
+	defaultBGMSampleRate = 48000
+	defaultBGMChannels   = 2
+)
+
+// decodeBGM decodes an interleaved audio stream, with or without an SShd header.
+// Non-zero fields of override take precedence over the header, anything still missing
+// falls back to the defaults, and the interleave of ADPCM data is detected if it's unknown.
+// It returns the parameters the stream was decoded with.
+func decodeBGM(data []byte, override audio.StreamHeader) (*audio.WAV, audio.StreamHeader, error) {
+	h, _, dataStart, err := audio.FindStreamHeader(data)
+	switch {
+	case err == nil:
+		data = data[dataStart:]
+	case errors.Is(err, audio.ErrNoStreamHeader):
+		// only data without any SShd is headerless, a broken header is an error
+		h = audio.StreamHeader{Codec: audio.CodecADPCM}
+	default:
+		return nil, h, err
+	}
+
+	if override.SampleRate != 0 {
+		h.SampleRate = override.SampleRate
+	}
+	if override.Channels != 0 {
+		h.Channels = override.Channels
+	}
+	if override.Interleave != 0 {
+		h.Interleave = override.Interleave
+	}
+
+	if h.SampleRate == 0 {
+		h.SampleRate = defaultBGMSampleRate
+	}
+	if h.Channels == 0 {
+		h.Channels = defaultBGMChannels
+	}
+
+	if h.Codec == audio.CodecADPCM && h.Channels > 1 && h.Interleave == 0 {
+		if h.DataSize != 0 && int(h.DataSize) < len(data) {
+			data = data[:h.DataSize]
+		}
+
+		interleave, err := audio.DetectInterleave(data, int(h.Channels))
+		if err != nil {
+			return nil, h, err
+		}
+		h.Interleave = uint32(interleave)
+	}
+
+	wav, err := h.Decode(data)
+	return wav, h, err
+}
+
+func (opts *BGMOptions) Execute(args []string) error {
+	if opts.SampleRate < 0 || opts.Channels < 0 {
+		return fmt.Errorf("--sample-rate and --channels can't be negative")
+	}
+
+	override := audio.StreamHeader{
+		SampleRate: uint32(opts.SampleRate),
+		Channels:   uint32(opts.Channels),
+		Interleave: uint32(opts.Interleave),
+	}
+
+	for _, input := range opts.Pos.Inputs {
+		inPath := string(input)
+
+		outDir := string(opts.OutDir)
+		if outDir == "" {
+			outDir = filepath.Dir(inPath)
+		}
+
+		err := os.MkdirAll(outDir, 0700)
+		if err != nil {
+			return fmt.Errorf("Can't create output dir %s: %v", outDir, err)
+		}
+
+		data, err := os.ReadFile(inPath)
+		if err != nil {
+			return fmt.Errorf("Can't read file: %v", err)
+		}
+
+		wav, h, err := decodeBGM(data, override)
+		if err != nil {
+			return fmt.Errorf("Can't decode %s: %v", inPath, err)
+		}
+
+		wavPath := filepath.Join(outDir, trimExt(filepath.Base(inPath))+".wav")
+		err = writeWAVFile(wavPath, wav)
+		if err != nil {
+			return fmt.Errorf("Can't write %s: %v", wavPath, err)
+		}
+
+		fmt.Printf("%s: %d channels at %d Hz, interleave 0x%X, %d samples per channel\n",
+			inPath, h.Channels, h.SampleRate, h.Interleave, len(wav.Samples)/wav.Channels)
+		if opts.Debug {
+			fmt.Printf("%+v\n", h)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+	"sh2unpack/sh2"
+)
+
+// diffSide is one of the two versions being compared.
+type diffSide struct {
+	input       *gameInput
+	gameVersion sh2.GameVersion
+	files       map[string]sh2.DataFile
+	paths       []string // in table order
+}
+
+func (opts *DiffOptions) loadSide(p string, filter *pathFilter) (*diffSide, error) {
+	inputOpts := inputOptionsForPath(p, opts.SkipISOHash, opts.VersionFile)
+
+	input, err := inputOpts.open(opts.Debug, opts.BinaryFiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+
+	input.printVersion()
+
+	dataFiles, err := filter.collect(input)
+	if err != nil {
+		_ = input.Close()
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+
+	side := &diffSide{
+		input:       input,
+		gameVersion: input.Version,
+		files:       map[string]sh2.DataFile{},
+	}
+
+	for _, dataFile := range dataFiles {
+		// paths listed more than once point to the same chunk in practice, only keep the first
+		if _, ok := side.files[dataFile.Path]; ok {
+			continue
+		}
+
+		side.files[dataFile.Path] = dataFile
+		side.paths = append(side.paths, dataFile.Path)
+	}
+
+	return side, nil
+}
+
+func printDiffSection(title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Printf("%s (%d):\n", title, len(lines))
+	for _, line := range lines {
+		fmt.Printf("  %s\n", line)
+	}
+}
+
+func (opts *DiffOptions) Execute(args []string) error {
+	filter, err := opts.FilterOptions.compile()
+	if err != nil {
+		return err
+	}
+
+	oldSide, err := opts.loadSide(string(opts.Pos.Old), filter)
+	if err != nil {
+		return err
+	}
+	defer oldSide.input.Close()
+
+	newSide, err := opts.loadSide(string(opts.Pos.New), filter)
+	if err != nil {
+		return err
+	}
+	defer newSide.input.Close()
+
+	fmt.Printf("Comparing %s (%s) to %s (%s)\n",
+		oldSide.gameVersion.FileName, oldSide.gameVersion.Description,
+		newSide.gameVersion.FileName, newSide.gameVersion.Description)
+
+	var added, removed, sizeChanged, contentChanged []string
+	numUnchanged := 0
+
+	for _, p := range oldSide.paths {
+		if _, ok := newSide.files[p]; !ok {
+			removed = append(removed, fmt.Sprintf("%s (0x%X bytes)", p, oldSide.files[p].ChunkLength))
+		}
+	}
+
+	for _, p := range newSide.paths {
+		newFile := newSide.files[p]
+		oldFile, ok := oldSide.files[p]
+		if !ok {
+			added = append(added, fmt.Sprintf("%s (0x%X bytes)", p, newFile.ChunkLength))
+			continue
+		}
+
+		if oldFile.ChunkLength != newFile.ChunkLength {
+			sizeChanged = append(sizeChanged, fmt.Sprintf("%s: 0x%X -> 0x%X bytes", p, oldFile.ChunkLength, newFile.ChunkLength))
+			continue
+		}
+
+		// sizes match, so the contents need to be compared
+		oldHash, _, err := hashChunk(oldSide.input, oldFile)
+		if err != nil {
+			return fmt.Errorf("Can't hash %s in %s: %v", p, opts.Pos.Old, err)
+		}
+
+		newHash, _, err := hashChunk(newSide.input, newFile)
+		if err != nil {
+			return fmt.Errorf("Can't hash %s in %s: %v", p, opts.Pos.New, err)
+		}
+
+		if oldHash != newHash {
+			contentChanged = append(contentChanged, fmt.Sprintf("%s: %s -> %s", p, oldHash, newHash))
+			continue
+		}
+
+		numUnchanged++
+	}
+
+	if opts.SortByPath {
+		for _, lines := range [][]string{added, removed, sizeChanged, contentChanged} {
+			slices.Sort(lines)
+		}
+	}
+
+	printDiffSection("Added", added)
+	printDiffSection("Removed", removed)
+	printDiffSection("Changed in size", sizeChanged)
+	printDiffSection("Changed in content", contentChanged)
+
+	fmt.Printf("%d added, %d removed, %d changed in size, %d changed in content, %d unchanged.\n",
+		len(added), len(removed), len(sizeChanged), len(contentChanged), numUnchanged)
+
+	return nil
+}
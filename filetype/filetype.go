@@ -0,0 +1,130 @@
+// Package filetype detects the type of the game's files by their contents.
+// Most files inside the mergefiles have meaningful extensions, but quite a few don't, and some are plain wrong.
+package filetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// SniffLength is the number of bytes Detect needs to see to recognize every type.
+const SniffLength = 64
+
+// Type is a kind of file. Name is short and unique, it's used in listings and as a directory name.
+type Type struct {
+	Name        string
+	Extension   string // including the dot, empty for Unknown
+	Description string
+}
+
+func (t Type) String() string {
+	return t.Name
+}
+
+var (
+	Unknown = Type{Name: "unknown", Description: "Unknown"}
+
+	PSS       = Type{Name: "pss", Extension: ".pss", Description: "MPEG-2 program stream (PSS movie)"}
+	M2V       = Type{Name: "m2v", Extension: ".m2v", Description: "MPEG-2 video elementary stream"}
+	TIM2      = Type{Name: "tim2", Extension: ".tm2", Description: "TIM2 texture"}
+	VAG       = Type{Name: "vag", Extension: ".vag", Description: "VAG ADPCM audio"}
+	VAGi      = Type{Name: "vagi", Extension: ".vag", Description: "Interleaved VAG ADPCM audio"}
+	ADS       = Type{Name: "ads", Extension: ".ads", Description: "SShd/SSbd audio stream"}
+	SoundBank = Type{Name: "hd", Extension: ".hd", Description: "Sound bank header"}
+	WAV       = Type{Name: "wav", Extension: ".wav", Description: "RIFF WAVE audio"}
+	PNG       = Type{Name: "png", Extension: ".png", Description: "PNG image"}
+	IRX       = Type{Name: "irx", Extension: ".irx", Description: "IOP module"}
+	ELF       = Type{Name: "elf", Extension: ".elf", Description: "ELF executable"}
+)
+
+// signature matches a type by the bytes at the start of a file.
+type signature struct {
+	t     Type
+	match func(header []byte) bool
+}
+
+func prefix(p string) func([]byte) bool {
+	return func(header []byte) bool {
+		return bytes.HasPrefix(header, []byte(p))
+	}
+}
+
+// the order matters, IRX modules are ELF files too
+var signatures = []signature{
+	{PSS, prefix("\x00\x00\x01\xBA")},
+	{M2V, prefix("\x00\x00\x01\xB3")},
+	{TIM2, prefix("TIM2")},
+	{VAG, prefix("VAGp")},
+	{VAGi, prefix("VAGi")},
+	{ADS, prefix("SShd")},
+	{SoundBank, prefix("IECSsreV")},
+	{WAV, func(header []byte) bool {
+		return len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE"))
+	}},
+	{PNG, prefix("\x89PNG\r\n\x1A\n")},
+	{IRX, func(header []byte) bool {
+		// IOP modules have their own ELF file type
+		return len(header) >= 18 && bytes.HasPrefix(header, []byte("\x7FELF")) && binary.LittleEndian.Uint16(header[16:18]) == 0xFF80
+	}},
+	{ELF, prefix("\x7FELF")},
+}
+
+// Detect returns the type of a file given its first SniffLength bytes. Shorter headers are fine.
+func Detect(header []byte) Type {
+	for _, sig := range signatures {
+		if sig.match(header) {
+			return sig.t
+		}
+	}
+
+	return Unknown
+}
+
+// DetectReader reads up to SniffLength bytes from r and returns the file's type.
+func DetectReader(r io.Reader) (Type, error) {
+	header := make([]byte, SniffLength)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Unknown, err
+	}
+
+	return Detect(header[:n]), nil
+}
+
+// Sniffer is an io.Writer that keeps the first SniffLength bytes written to it,
+// so a file's type can be detected while it's being copied somewhere else.
+type Sniffer struct {
+	header []byte
+}
+
+func (s *Sniffer) Write(p []byte) (int, error) {
+	if n := SniffLength - len(s.header); n > 0 {
+		s.header = append(s.header, p[:min(n, len(p))]...)
+	}
+
+	return len(p), nil
+}
+
+// Type returns the type of what has been written so far.
+func (s *Sniffer) Type() Type {
+	return Detect(s.header)
+}
+
+// FixExtension returns the slash-separated path p with its extension replaced by the type's,
+// unless it already has the right one. Paths of unknown files are returned as they are.
+func FixExtension(p string, t Type) string {
+	if t.Extension == "" {
+		return p
+	}
+
+	ext := path.Ext(p)
+	if strings.EqualFold(ext, t.Extension) {
+		return p
+	}
+
+	return strings.TrimSuffix(p, ext) + t.Extension
+}
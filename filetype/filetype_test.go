@@ -0,0 +1,115 @@
+package filetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// elfHeader returns the start of an ELF file with the given e_type.
+func elfHeader(elfType uint16) []byte {
+	header := make([]byte, 52)
+	copy(header, "\x7FELF\x01\x01\x01")
+	binary.LittleEndian.PutUint16(header[16:], elfType)
+	binary.LittleEndian.PutUint16(header[18:], 8) // MIPS
+	return header
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   Type
+	}{
+		{"PSS", []byte("\x00\x00\x01\xBA\x44\x00\x04\x00"), PSS},
+		{"M2V", []byte("\x00\x00\x01\xB3\x28\x01\xE0\x13"), M2V},
+		{"TIM2", []byte("TIM2\x04\x00\x01\x00"), TIM2},
+		{"VAGp", []byte("VAGp\x00\x00\x00\x20"), VAG},
+		{"VAGi", []byte("VAGi\x00\x00\x00\x20"), VAGi},
+		{"ADS", []byte("SShd\x18\x00\x00\x00"), ADS},
+		{"sound bank", []byte("IECSsreV\x10\x00\x00\x00"), SoundBank},
+		{"WAV", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), WAV},
+		{"RIFF that isn't WAV", []byte("RIFF\x24\x00\x00\x00AVI LIST"), Unknown},
+		{"PNG", []byte("\x89PNG\r\n\x1A\n\x00\x00\x00\x0DIHDR"), PNG},
+		{"IRX", elfHeader(0xFF80), IRX},
+		{"ELF", elfHeader(2), ELF},
+		{"unknown", []byte(strings.Repeat("maria", 20)), Unknown},
+		{"zeros", make([]byte, SniffLength), Unknown},
+		{"empty", nil, Unknown},
+		{"short PSS", []byte("\x00\x00\x01"), Unknown},
+		{"short WAV", []byte("RIFF\x24\x00\x00\x00WA"), Unknown},
+		{"short IRX", elfHeader(0xFF80)[:17], ELF},
+		{"magic not at the start", []byte("\x00TIM2"), Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.header); got != tt.want {
+			t.Errorf("%s: Detect = %s, want %s", tt.name, got, tt.want)
+		}
+
+		got, err := DetectReader(bytes.NewReader(tt.header))
+		if err != nil {
+			t.Errorf("%s: DetectReader: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("%s: DetectReader = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSniffer(t *testing.T) {
+	wav := append([]byte("RIFF\x24\x00\x00\x00WAVEfmt "), make([]byte, 100)...)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		writeSize int
+		want      Type
+	}{
+		{"one write", wav, len(wav), WAV},
+		{"byte by byte", wav, 1, WAV},
+		{"split magic", wav, 6, WAV},
+		{"nothing written", nil, 1, Unknown},
+		// only the first SniffLength bytes count
+		{"magic too late", append(make([]byte, SniffLength), "TIM2"...), 7, Unknown},
+	}
+
+	for _, tt := range tests {
+		var s Sniffer
+		for pos := 0; pos < len(tt.data); pos += tt.writeSize {
+			chunk := tt.data[pos:min(pos+tt.writeSize, len(tt.data))]
+			n, err := s.Write(chunk)
+			if n != len(chunk) || err != nil {
+				t.Errorf("%s: Write = %d, %v, want %d, nil", tt.name, n, err, len(chunk))
+			}
+		}
+
+		if got := s.Type(); got != tt.want {
+			t.Errorf("%s: Type = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFixExtension(t *testing.T) {
+	tests := []struct {
+		path string
+		t    Type
+		want string
+	}{
+		{"data/chr/maria.bin", TIM2, "data/chr/maria.tm2"},
+		{"data/chr/maria.tm2", TIM2, "data/chr/maria.tm2"},
+		{"data/chr/maria.TM2", TIM2, "data/chr/maria.TM2"},
+		{"data/chr/maria", TIM2, "data/chr/maria.tm2"},
+		{"data/chr.old/maria", TIM2, "data/chr.old/maria.tm2"},
+		{"data/chr/maria.tar.bin", TIM2, "data/chr/maria.tar.tm2"},
+		{"data/snd/voice.vag", VAGi, "data/snd/voice.vag"},
+		{"data/bgm/theme.bgm", ADS, "data/bgm/theme.ads"},
+		{"data/chr/maria.bin", Unknown, "data/chr/maria.bin"},
+	}
+
+	for _, tt := range tests {
+		if got := FixExtension(tt.path, tt.t); got != tt.want {
+			t.Errorf("FixExtension(%q, %s) = %q, want %q", tt.path, tt.t, got, tt.want)
+		}
+	}
+}
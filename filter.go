@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"sh2unpack/sh2"
+	"sh2unpack/utils"
+)
+
+// pathFilter decides which data files a command operates on.
+type pathFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	paths   map[string]bool
+}
+
+// compile turns the filter options into a pathFilter.
+func (opts *FilterOptions) compile() (*pathFilter, error) {
+	filter := pathFilter{}
+
+	for _, pattern := range opts.Include {
+		re, err := utils.GlobToRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid include pattern %q: %v", pattern, err)
+		}
+		filter.include = append(filter.include, re)
+	}
+
+	for _, pattern := range opts.IncludeRegex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid include regex %q: %v", pattern, err)
+		}
+		filter.include = append(filter.include, re)
+	}
+
+	for _, pattern := range opts.Exclude {
+		re, err := utils.GlobToRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid exclude pattern %q: %v", pattern, err)
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+
+	for _, pattern := range opts.ExcludeRegex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid exclude regex %q: %v", pattern, err)
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+
+	if opts.IncludeList != "" {
+		paths, err := readPathList(string(opts.IncludeList))
+		if err != nil {
+			return nil, fmt.Errorf("Can't read path list: %v", err)
+		}
+		filter.paths = paths
+	}
+
+	return &filter, nil
+}
+
+// readPathList reads a file with one path per line. Empty lines and lines starting with # are ignored.
+func readPathList(listPath string) (map[string]bool, error) {
+	f, err := os.Open(listPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	paths := map[string]bool{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(strings.ReplaceAll(line, "\\", "/"), "./")
+		paths[line] = true
+	}
+
+	return paths, scanner.Err()
+}
+
+// matches reports whether a data file path passes the filter.
+// If there are no include patterns and no path list, everything that isn't excluded matches.
+func (f *pathFilter) matches(p string) bool {
+	for _, re := range f.exclude {
+		if re.MatchString(p) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 && f.paths == nil {
+		return true
+	}
+
+	if f.paths[p] {
+		return true
+	}
+
+	for _, re := range f.include {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collect returns every entry that passes the filter, in table order.
+// Binary files are only among the entries if the game was opened with them.
+func (f *pathFilter) collect(input *gameInput) ([]sh2.DataFile, error) {
+	entries, err := input.Entries()
+	if err != nil {
+		return nil, fmt.Errorf("Can't resolve data files: %v", err)
+	}
+
+	return utils.Filter(entries, func(dataFile sh2.DataFile) bool {
+		return f.matches(dataFile.Path)
+	}), nil
+}
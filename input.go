@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"sh2unpack/iso"
+	"sh2unpack/sh2"
+)
+
+// gameInput is the game as read by sh2.Archive, plus what's needed to write to it.
+type gameInput struct {
+	*sh2.Archive
+
+	// ImagePath is the disc image the game was read from, if any
+	ImagePath string
+}
+
+// open opens and identifies the game and reads its data map.
+// Standalone binary files are only indexed if binaryFiles is set.
+func (opts *InputOptions) open(debug, binaryFiles bool) (*gameInput, error) {
+	inFilePath := string(opts.InFile)
+	isoFilePath := string(opts.ISOFile)
+
+	if opts.MagicOffset != 0 && opts.DataOffset == 0 {
+		return nil, errors.New("--magic-offset requires --data-offset")
+	}
+
+	err := opts.loadVersionFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	archiveOpts := sh2.ArchiveOptions{
+		DataOffset:  uint32(opts.DataOffset),
+		MagicOffset: uint32(opts.MagicOffset),
+		SkipISOHash: opts.SkipISOHash,
+		BinaryFiles: binaryFiles,
+		Debug:       debug,
+		Logf: func(format string, a ...any) {
+			fmt.Printf(format+"\n", a...)
+		},
+	}
+
+	var archive *sh2.Archive
+
+	switch {
+	case inFilePath != "" && isoFilePath != "":
+		return nil, errors.New("--infile and --iso can't be used at the same time")
+	case isoFilePath != "":
+		archive, err = sh2.OpenDiscImage(isoFilePath, archiveOpts)
+	case inFilePath != "":
+		archive, err = sh2.OpenGameFolder(inFilePath, archiveOpts)
+	default:
+		return nil, errors.New("Either --infile or --iso is required")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Can't open game: %w", err)
+	}
+
+	return &gameInput{
+		Archive:   archive,
+		ImagePath: isoFilePath,
+	}, nil
+}
+
+// printVersion prints the detected game version.
+func (g *gameInput) printVersion() {
+	fmt.Printf("Version detected: %s, %s\n", g.Version.FileName, g.Version.Description)
+}
+
+// commands that work with multiple inputs only need to load the version files once
+var versionFilesLoaded = false
+
+// loadVersionFiles merges the user's version file from the config directory and the one passed via --versions
+// into the built-in version table.
+func (opts *InputOptions) loadVersionFiles() error {
+	if versionFilesLoaded {
+		return nil
+	}
+	versionFilesLoaded = true
+
+	var versionFiles []string
+
+	configDir, err := os.UserConfigDir()
+	if err == nil {
+		userVersionFile := filepath.Join(configDir, "sh2unpack", "versions.json")
+		if _, err := os.Stat(userVersionFile); err == nil {
+			versionFiles = append(versionFiles, userVersionFile)
+		}
+	}
+
+	if opts.VersionFile != "" {
+		versionFiles = append(versionFiles, string(opts.VersionFile))
+	}
+
+	for _, versionFile := range versionFiles {
+		numVersions, err := sh2.LoadVersionFile(versionFile)
+		if err != nil {
+			return fmt.Errorf("Can't load version file: %v", err)
+		}
+
+		fmt.Printf("Loaded %d additional versions from %s\n", numVersions, versionFile)
+	}
+
+	return nil
+}
+
+// inputOptionsForPath returns InputOptions for commands that take multiple inputs as arguments.
+// Paths ending in .iso are treated as disc images, everything else as a game binary.
+func inputOptionsForPath(p string, skipISOHash bool, versionFile flags.Filename) InputOptions {
+	opts := InputOptions{
+		SkipISOHash: skipISOHash,
+		VersionFile: versionFile,
+	}
+
+	if strings.EqualFold(filepath.Ext(p), ".iso") {
+		opts.ISOFile = flags.Filename(p)
+	} else {
+		opts.InFile = flags.Filename(p)
+	}
+
+	return opts
+}
+
+// openForPatching opens a file referenced by the path table for writing.
+// It also returns the offset of the file's data within the returned *os.File,
+// which is only ever non-zero when the game was read from a disc image.
+func (g *gameInput) openForPatching(p string) (*os.File, int64, error) {
+	discPath := sh2.DiscPath(p)
+
+	if g.ImagePath == "" {
+		f, err := os.OpenFile(filepath.Join(filepath.Dir(g.BinaryPath), filepath.FromSlash(discPath)), os.O_WRONLY, 0)
+		return f, 0, err
+	}
+
+	imageFile, err := g.FS.Open(discPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset := imageFile.(*iso.File).Offset()
+	_ = imageFile.Close()
+
+	f, err := os.OpenFile(g.ImagePath, os.O_WRONLY, 0)
+	return f, offset, err
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"sh2unpack/sh2"
+)
+
+// typedEntry is an entry of the typed entry table, which mixes binary files, mergefiles and data files.
+type typedEntry struct {
+	address   uint32
+	entryType sh2.FileEntryType
+	mgfEntry  sh2.MergeFileEntry // binary files and mergefiles
+	datEntry  sh2.DataFileEntry  // data files
+}
+
+// typedEntries puts the typed entry table back together in table order.
+func typedEntries(dataMap *sh2.DataMap) []typedEntry {
+	var entries []typedEntry
+
+	for addr, entry := range dataMap.GetBinaryFileEntries() {
+		entries = append(entries, typedEntry{address: addr, entryType: sh2.EntryTypeBinaryFile, mgfEntry: entry})
+	}
+	for addr, entry := range dataMap.GetMergeFileEntries() {
+		entries = append(entries, typedEntry{address: addr, entryType: sh2.EntryTypeMergeFile, mgfEntry: entry})
+	}
+	for addr, entry := range dataMap.GetDataFileEntries() {
+		entries = append(entries, typedEntry{address: addr, entryType: sh2.EntryTypeDataFile, datEntry: entry})
+	}
+
+	slices.SortFunc(entries, func(a, b typedEntry) int {
+		return cmp.Compare(a.address, b.address)
+	})
+
+	return entries
+}
+
+// inspector prints the tables with everything they resolve to.
+type inspector struct {
+	dataMap *sh2.DataMap
+	w       *tabwriter.Writer
+
+	// paths of the file-path entries, keyed by the address of the entry they point to
+	entryPaths map[uint32]string
+	// number of references to each path
+	pathRefs map[uint32]int
+}
+
+// offset returns the file offset of a virtual address as a string, or a question mark.
+func (in *inspector) offset(address uint32) string {
+	offset, ok := in.dataMap.FileOffset(address)
+	if !ok {
+		return "?"
+	}
+	return fmt.Sprintf("0x%08X", offset)
+}
+
+// path returns the path at a virtual address, or a placeholder if there's nothing there.
+func (in *inspector) path(address uint32) string {
+	p, ok := in.dataMap.GetFilePath(address)
+	if !ok {
+		return "(no path)"
+	}
+	return p
+}
+
+func (in *inspector) printFilePathTable(dataOffset uint32) {
+	fmt.Printf("File-to-path table: %d entries\n", len(in.dataMap.FileToPathOffsets))
+	_, _ = fmt.Fprintln(in.w, "INDEX\tOFFSET\tADDRESS\tENTRY\tTYPE\tPATH ADDRESS\tPATH")
+
+	for i, ftp := range in.dataMap.FileToPathOffsets {
+		offset := dataOffset + uint32(i)*8
+
+		entryType := "(no entry)"
+		if _, ok := in.dataMap.GetBinaryFileEntry(ftp.FileOffset); ok {
+			entryType = sh2.EntryTypeBinaryFile.String()
+		} else if _, ok := in.dataMap.GetMergeFileEntry(ftp.FileOffset); ok {
+			entryType = sh2.EntryTypeMergeFile.String()
+		} else if _, ok := in.dataMap.GetDataFileEntry(ftp.FileOffset); ok {
+			entryType = sh2.EntryTypeDataFile.String()
+		}
+
+		_, _ = fmt.Fprintf(in.w, "%d\t0x%08X\t0x%08X\t0x%08X\t%s\t0x%08X\t%s\n",
+			i, offset, offset+in.dataMap.MagicOffset(), ftp.FileOffset, entryType, ftp.PathOffset, in.path(ftp.PathOffset))
+	}
+
+	_ = in.w.Flush()
+	fmt.Println()
+}
+
+func (in *inspector) printTypedEntryTable() {
+	entries := typedEntries(in.dataMap)
+
+	fmt.Printf("Typed entry table: %d entries\n", len(entries))
+	_, _ = fmt.Fprintln(in.w, "OFFSET\tADDRESS\tTYPE\tFIELDS\tRESOLVED")
+
+	for _, e := range entries {
+		var fields, resolved string
+
+		switch e.entryType {
+		case sh2.EntryTypeBinaryFile, sh2.EntryTypeMergeFile:
+			fields = e.mgfEntry.String()
+			resolved = in.path(e.mgfEntry.PathOffset)
+		case sh2.EntryTypeDataFile:
+			fields = e.datEntry.String()
+
+			mgfPath := "(no mergefile)"
+			if mgfAddr, err := in.dataMap.GetMergeFileAddressFromDataFileEntry(e.datEntry); err == nil {
+				mgfEntry, _ := in.dataMap.GetMergeFileEntry(mgfAddr)
+				mgfPath = fmt.Sprintf("%s (entry at 0x%08X)", in.path(mgfEntry.PathOffset), mgfAddr)
+			}
+
+			datPath, ok := in.entryPaths[e.address]
+			if !ok {
+				datPath = "(unreferenced)"
+			}
+
+			resolved = fmt.Sprintf("%s in %s", datPath, mgfPath)
+		}
+
+		_, _ = fmt.Fprintf(in.w, "%s\t0x%08X\t%s\t%s\t%s\n", in.offset(e.address), e.address, e.entryType, fields, resolved)
+	}
+
+	_ = in.w.Flush()
+	fmt.Println()
+}
+
+func (in *inspector) printPathTable() {
+	paths := in.dataMap.GetFilePaths()
+	addresses := maps.Keys(paths)
+	slices.Sort(addresses)
+
+	fmt.Printf("Path table: %d paths\n", len(paths))
+	_, _ = fmt.Fprintln(in.w, "OFFSET\tADDRESS\tREFERENCES\tPATH")
+
+	for _, addr := range addresses {
+		_, _ = fmt.Fprintf(in.w, "%s\t0x%08X\t%d\t%s\n", in.offset(addr), addr, in.pathRefs[addr], paths[addr])
+	}
+
+	_ = in.w.Flush()
+	fmt.Println()
+}
+
+func (opts *InspectOptions) Execute(args []string) error {
+	input, err := opts.InputOptions.open(opts.Debug, false)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	input.printVersion()
+
+	dataMap := input.DataMap
+
+	in := &inspector{
+		dataMap:    dataMap,
+		w:          tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0),
+		entryPaths: map[uint32]string{},
+		pathRefs:   map[uint32]int{},
+	}
+
+	for _, ftp := range dataMap.FileToPathOffsets {
+		if p, ok := dataMap.GetFilePath(ftp.PathOffset); ok {
+			in.entryPaths[ftp.FileOffset] = p
+		}
+		in.pathRefs[ftp.PathOffset]++
+	}
+	for _, entry := range dataMap.GetBinaryFileEntries() {
+		in.pathRefs[entry.PathOffset]++
+	}
+	for _, entry := range dataMap.GetMergeFileEntries() {
+		in.pathRefs[entry.PathOffset]++
+	}
+
+	fmt.Printf("Data offset: 0x%X, magic offset: 0x%X\n\n", input.Version.DataOffset, dataMap.MagicOffset())
+
+	tables := opts.Tables
+	if len(tables) == 0 {
+		tables = []string{"file-paths", "entries", "paths"}
+	}
+
+	for _, table := range tables {
+		switch table {
+		case "file-paths":
+			in.printFilePathTable(input.Version.DataOffset)
+		case "entries":
+			in.printTypedEntryTable()
+		case "paths":
+			in.printPathTable()
+		}
+	}
+
+	return nil
+}
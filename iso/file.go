@@ -0,0 +1,94 @@
+package iso
+
+import (
+	"io"
+	"io/fs"
+	"time"
+)
+
+// File is an open file or directory on the disc.
+// Reads are served straight from the underlying image, so it also implements io.ReaderAt and io.Seeker.
+type File struct {
+	*io.SectionReader
+	entry  *entry
+	dirPos int
+}
+
+// Stat returns a fs.FileInfo describing the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	return fileInfo{f.entry}, nil
+}
+
+// Close does nothing, the underlying image is owned by whoever opened it.
+func (f *File) Close() error {
+	return nil
+}
+
+// Offset returns the absolute offset of the file's data within the image.
+func (f *File) Offset() int64 {
+	return int64(f.entry.lba) * SectorSize
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.entry.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: f.entry.name, Err: fs.ErrInvalid}
+	}
+
+	remaining := f.entry.children[f.dirPos:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > 0 && n < len(remaining) {
+		remaining = remaining[:n]
+	}
+
+	entries := make([]fs.DirEntry, len(remaining))
+	for i, child := range remaining {
+		entries[i] = fileInfo{child}
+	}
+	f.dirPos += len(remaining)
+
+	return entries, nil
+}
+
+// fileInfo implements both fs.FileInfo and fs.DirEntry.
+type fileInfo struct {
+	e *entry
+}
+
+func (fi fileInfo) Name() string {
+	return fi.e.name
+}
+
+func (fi fileInfo) Size() int64 {
+	return int64(fi.e.size)
+}
+
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.e.isDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi fileInfo) ModTime() time.Time {
+	return fi.e.modTime
+}
+
+func (fi fileInfo) IsDir() bool {
+	return fi.e.isDir
+}
+
+func (fi fileInfo) Sys() any {
+	return nil
+}
+
+func (fi fileInfo) Type() fs.FileMode {
+	return fi.Mode().Type()
+}
+
+func (fi fileInfo) Info() (fs.FileInfo, error) {
+	return fi, nil
+}
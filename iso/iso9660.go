@@ -0,0 +1,259 @@
+// Package iso implements a minimal read-only ISO 9660 file system.
+// It does just enough to get at the files on a PS2 disc image without having to copy them out first.
+package iso
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	SectorSize = 2048
+
+	// the first volume descriptor lives at sector 16, everything before that is the system area
+	volumeDescriptorStart = 16
+
+	volumeDescriptorPrimary    = 0x01
+	volumeDescriptorTerminator = 0xFF
+
+	flagDirectory = 0x02
+)
+
+var (
+	ErrNotISO9660         = errors.New("not an ISO 9660 image")
+	ErrNoPrimaryVolume    = errors.New("no primary volume descriptor found")
+	ErrMalformedDirectory = errors.New("malformed directory record")
+)
+
+// Image is a parsed ISO 9660 disc image. It implements fs.FS, fs.ReadDirFS and fs.StatFS.
+// The directory tree is read in its entirety when the image is opened, so an Image is safe for concurrent use.
+type Image struct {
+	r        io.ReaderAt
+	root     *entry
+	VolumeID string
+}
+
+// entry is a single file or directory on the disc.
+type entry struct {
+	name     string
+	lba      uint32
+	size     uint32
+	isDir    bool
+	modTime  time.Time
+	children []*entry
+}
+
+// Open parses the volume descriptors and the complete directory tree of an ISO 9660 image.
+func Open(r io.ReaderAt) (*Image, error) {
+	sector := make([]byte, SectorSize)
+
+	for i := int64(volumeDescriptorStart); ; i++ {
+		_, err := r.ReadAt(sector, i*SectorSize)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrNotISO9660
+			}
+			return nil, err
+		}
+
+		if string(sector[1:6]) != "CD001" {
+			return nil, ErrNotISO9660
+		}
+
+		switch sector[0] {
+		case volumeDescriptorPrimary:
+			img := &Image{
+				r:        r,
+				VolumeID: strings.TrimRight(string(sector[40:72]), " "),
+			}
+
+			// the root directory record is embedded in the primary volume descriptor at 0x9C
+			root, err := parseRecord(sector[156:190])
+			if err != nil {
+				return nil, err
+			}
+			root.name = "."
+
+			err = img.readDir(root, map[uint32]bool{})
+			if err != nil {
+				return nil, err
+			}
+
+			img.root = root
+			return img, nil
+		case volumeDescriptorTerminator:
+			return nil, ErrNoPrimaryVolume
+		}
+	}
+}
+
+// parseRecord parses a single directory record.
+func parseRecord(rec []byte) (*entry, error) {
+	if len(rec) < 33 {
+		return nil, ErrMalformedDirectory
+	}
+
+	nameLen := int(rec[32])
+	if 33+nameLen > len(rec) {
+		return nil, ErrMalformedDirectory
+	}
+
+	e := &entry{
+		// both-endian fields, we only care about the little endian half
+		lba:     binary.LittleEndian.Uint32(rec[2:6]),
+		size:    binary.LittleEndian.Uint32(rec[10:14]),
+		isDir:   rec[25]&flagDirectory != 0,
+		modTime: parseRecordTime(rec[18:25]),
+	}
+
+	name := string(rec[33 : 33+nameLen])
+	if !e.isDir {
+		// strip the version suffix and the trailing dot of extensionless files ("NAME.;1" -> "NAME")
+		name, _, _ = strings.Cut(name, ";")
+		name = strings.TrimSuffix(name, ".")
+	}
+	e.name = name
+
+	return e, nil
+}
+
+// parseRecordTime decodes the 7-byte recording date of a directory record.
+func parseRecordTime(b []byte) time.Time {
+	// the last byte is the offset from GMT in 15 minute intervals
+	loc := time.FixedZone("", int(int8(b[6]))*15*60)
+	return time.Date(1900+int(b[0]), time.Month(b[1]), int(b[2]), int(b[3]), int(b[4]), int(b[5]), 0, loc)
+}
+
+// readDir recursively reads the contents of a directory.
+// visited keeps track of directory extents so a broken image can't send us into an endless loop.
+func (img *Image) readDir(dir *entry, visited map[uint32]bool) error {
+	if visited[dir.lba] {
+		return fmt.Errorf("%w: directory loop at sector %d", ErrMalformedDirectory, dir.lba)
+	}
+	visited[dir.lba] = true
+
+	data := make([]byte, dir.size)
+	_, err := img.r.ReadAt(data, int64(dir.lba)*SectorSize)
+	if err != nil {
+		return err
+	}
+
+	for off := 0; off < len(data); {
+		recLen := int(data[off])
+		if recLen == 0 {
+			// records never cross sector boundaries, the rest of this sector is padding
+			off = (off/SectorSize + 1) * SectorSize
+			continue
+		}
+
+		if off+recLen > len(data) {
+			return ErrMalformedDirectory
+		}
+
+		child, err := parseRecord(data[off : off+recLen])
+		if err != nil {
+			return err
+		}
+		off += recLen
+
+		// skip the "." and ".." entries
+		if child.name == "\x00" || child.name == "\x01" {
+			continue
+		}
+
+		if child.isDir {
+			err = img.readDir(child, visited)
+			if err != nil {
+				return err
+			}
+		}
+
+		dir.children = append(dir.children, child)
+	}
+
+	// fs.ReadDirFS wants directory entries sorted by name
+	slices.SortFunc(dir.children, func(a, b *entry) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	return nil
+}
+
+// lookup resolves a slash-separated path. Names are matched case-insensitively,
+// since ISO 9660 file names are always upper case.
+func (img *Image) lookup(op, name string) (*entry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	e := img.root
+	if name == "." {
+		return e, nil
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		var next *entry
+		for _, child := range e.children {
+			if strings.EqualFold(child.name, part) {
+				next = child
+				break
+			}
+		}
+
+		if next == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		e = next
+	}
+
+	return e, nil
+}
+
+// Open opens the named file or directory.
+func (img *Image) Open(name string) (fs.File, error) {
+	e, err := img.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		SectionReader: io.NewSectionReader(img.r, int64(e.lba)*SectorSize, int64(e.size)),
+		entry:         e,
+	}, nil
+}
+
+// ReadDir returns the contents of the named directory.
+func (img *Image) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := img.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries := make([]fs.DirEntry, len(e.children))
+	for i, child := range e.children {
+		entries[i] = fileInfo{child}
+	}
+
+	return entries, nil
+}
+
+// Stat returns a fs.FileInfo describing the named file or directory.
+func (img *Image) Stat(name string) (fs.FileInfo, error) {
+	e, err := img.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo{e}, nil
+}
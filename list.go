@@ -0,0 +1,102 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/exp/slices"
+	"sh2unpack/filetype"
+	"sh2unpack/sh2"
+	"sh2unpack/utils"
+)
+
+func sortDataFiles(dataFiles []sh2.DataFile, sortBy string) {
+	switch sortBy {
+	case "path":
+		slices.SortStableFunc(dataFiles, func(a, b sh2.DataFile) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+	case "mergefile":
+		slices.SortStableFunc(dataFiles, func(a, b sh2.DataFile) int {
+			if c := strings.Compare(a.MergeFilePath, b.MergeFilePath); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.ChunkOffset, b.ChunkOffset)
+		})
+	case "size":
+		slices.SortStableFunc(dataFiles, func(a, b sh2.DataFile) int {
+			return cmp.Compare(a.ChunkLength, b.ChunkLength)
+		})
+	}
+}
+
+func (opts *ListOptions) Execute(args []string) error {
+	filter, err := opts.FilterOptions.compile()
+	if err != nil {
+		return err
+	}
+
+	input, err := opts.InputOptions.open(opts.Debug, opts.BinaryFiles)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	input.printVersion()
+
+	gameVersion, dataMap := input.Version, input.DataMap
+
+	dataFiles, err := filter.collect(input)
+	if err != nil {
+		return err
+	}
+
+	sortDataFiles(dataFiles, opts.Sort)
+	if opts.Reverse {
+		utils.Reverse(dataFiles)
+	}
+
+	var totalLength uint64
+	m := newManifest(gameVersion, dataMap)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PATH\tMERGEFILE\tOFFSET\tLENGTH\tTYPE")
+	for _, dataFile := range dataFiles {
+		mgfPath := dataFile.MergeFilePath
+		if dataFile.Standalone {
+			mgfPath = "(standalone)"
+		}
+
+		var fileType filetype.Type
+		if opts.Manifest != "" {
+			// the whole chunk has to be read for the hash anyway
+			var sha1Hash string
+			sha1Hash, fileType, err = hashChunk(input, dataFile)
+			if err != nil {
+				return fmt.Errorf("Can't hash %s: %v", dataFile.Path, err)
+			}
+
+			m.Files = append(m.Files, newManifestEntry(dataFile, fileType, sha1Hash))
+		} else {
+			fileType, err = sniffChunk(input, dataFile)
+			if err != nil {
+				return fmt.Errorf("Can't read %s: %v", dataFile.Path, err)
+			}
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t0x%08X\t%d\t%s\n", dataFile.Path, mgfPath, dataFile.ChunkOffset, dataFile.ChunkLength, fileType)
+		totalLength += uint64(dataFile.ChunkLength)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("%d files, %d bytes.\n", len(dataFiles), totalLength)
+
+	if opts.Manifest != "" {
+		return opts.ManifestOptions.write(m)
+	}
+
+	return nil
+}
@@ -33,6 +33,42 @@ func main() {
 	unpackCmd := UnpackOptions{}
 	_, _ = parser.AddCommand("unpack", "SH2 Unpacker", "Extracts files from SH2's game files", &unpackCmd)
 
+	listCmd := ListOptions{}
+	_, _ = parser.AddCommand("list", "SH2 Lister", "Lists the files in SH2's game files without extracting them", &listCmd)
+
+	repackCmd := RepackOptions{}
+	_, _ = parser.AddCommand("repack", "SH2 Repacker", "Rebuilds SH2's mergefiles and executable from a folder of extracted files", &repackCmd)
+
+	patchCmd := PatchOptions{}
+	_, _ = parser.AddCommand("patch", "SH2 Patcher", "Replaces a single file inside its mergefile in place, as long as it fits", &patchCmd)
+
+	orphansCmd := OrphansOptions{}
+	_, _ = parser.AddCommand("orphans", "SH2 Orphan Finder", "Reports data in mergefiles that isn't referenced by any table entry", &orphansCmd)
+
+	verifyCmd := VerifyOptions{}
+	_, _ = parser.AddCommand("verify", "SH2 Verifier", "Checks the data map for overlapping chunks, unresolvable entries and other oddities", &verifyCmd)
+
+	statsCmd := StatsOptions{}
+	_, _ = parser.AddCommand("stats", "SH2 Statistics", "Gathers statistics about the unknown fields of binary file and mergefile entries across game versions", &statsCmd)
+
+	diffCmd := DiffOptions{}
+	_, _ = parser.AddCommand("diff", "SH2 Differ", "Compares the files of two game versions", &diffCmd)
+
+	serveCmd := ServeOptions{}
+	_, _ = parser.AddCommand("serve", "SH2 Asset Browser", "Serves the game's files on a local web server for browsing", &serveCmd)
+
+	inspectCmd := InspectOptions{}
+	_, _ = parser.AddCommand("inspect", "SH2 Table Inspector", "Prints the executable's file tables with offsets, addresses and what they resolve to", &inspectCmd)
+
+	pssCmd := PSSOptions{}
+	_, _ = parser.AddCommand("pss", "SH2 PSS Demuxer", "Splits PSS movies into MPEG-2 video (.m2v) and decoded audio (.wav)", &pssCmd)
+
+	vagCmd := VAGOptions{}
+	_, _ = parser.AddCommand("vag", "SH2 VAG Decoder", "Decodes VAGp and headerless SPU ADPCM audio to WAV", &vagCmd)
+
+	bgmCmd := BGMOptions{}
+	_, _ = parser.AddCommand("bgm", "SH2 BGM Decoder", "Decodes interleaved SPU ADPCM music streams to WAV", &bgmCmd)
+
 	// print version
 	fmt.Printf("sh2unpack %s [%s]\n", constants.GitVersion, constants.GitCommitShort)
 
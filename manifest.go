@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sh2unpack/filetype"
+	"sh2unpack/sh2"
+)
+
+// manifestEntry describes a single file in a manifest.
+type manifestEntry struct {
+	Path             string `json:"path"`
+	Destination      string `json:"destination,omitempty"`
+	MergeFile        string `json:"mergeFile"`
+	Standalone       bool   `json:"standalone,omitempty"`
+	ChunkOffset      uint32 `json:"chunkOffset"`
+	ChunkLength      uint32 `json:"chunkLength"`
+	Type             string `json:"type"`
+	SHA1             string `json:"sha1"`
+	EntryAddress     uint32 `json:"entryAddress"`
+	PathAddress      uint32 `json:"pathAddress"`
+	MergeFileAddress uint32 `json:"mergeFileAddress"`
+}
+
+func newManifestEntry(dataFile sh2.DataFile, fileType filetype.Type, sha1Hash string) manifestEntry {
+	return manifestEntry{
+		Path:             dataFile.Path,
+		MergeFile:        dataFile.MergeFilePath,
+		Standalone:       dataFile.Standalone,
+		ChunkOffset:      dataFile.ChunkOffset,
+		ChunkLength:      dataFile.ChunkLength,
+		Type:             fileType.Name,
+		SHA1:             sha1Hash,
+		EntryAddress:     dataFile.EntryAddress,
+		PathAddress:      dataFile.PathAddress,
+		MergeFileAddress: dataFile.MergeFileAddress,
+	}
+}
+
+// manifest is a machine-readable index of every file in a version of the game.
+type manifest struct {
+	FileName    string          `json:"fileName"`
+	Description string          `json:"description"`
+	DataOffset  uint32          `json:"dataOffset"`
+	MagicOffset uint32          `json:"magicOffset"`
+	Files       []manifestEntry `json:"files"`
+}
+
+func newManifest(gameVersion sh2.GameVersion, dataMap *sh2.DataMap) *manifest {
+	return &manifest{
+		FileName:    gameVersion.FileName,
+		Description: gameVersion.Description,
+		DataOffset:  gameVersion.DataOffset,
+		MagicOffset: dataMap.MagicOffset(),
+		Files:       []manifestEntry{},
+	}
+}
+
+// hashChunk returns the SHA1 hash and the type of a data file's contents without extracting it.
+// Both come from the same read, so there's no need to call sniffChunk as well.
+func hashChunk(input *gameInput, dataFile sh2.DataFile) (string, filetype.Type, error) {
+	mergeFile, err := input.OpenFile(dataFile.MergeFilePath)
+	if err != nil {
+		return "", filetype.Unknown, err
+	}
+
+	h := sha1.New()
+	var sniffer filetype.Sniffer
+	_, err = io.Copy(io.MultiWriter(h, &sniffer), io.NewSectionReader(mergeFile, int64(dataFile.ChunkOffset), int64(dataFile.ChunkLength)))
+	if err != nil {
+		return "", filetype.Unknown, err
+	}
+
+	return fmt.Sprintf("%X", h.Sum(nil)), sniffer.Type(), nil
+}
+
+// sniffChunk returns the type of a data file's contents without extracting it.
+func sniffChunk(input *gameInput, dataFile sh2.DataFile) (filetype.Type, error) {
+	mergeFile, err := input.OpenFile(dataFile.MergeFilePath)
+	if err != nil {
+		return filetype.Unknown, err
+	}
+
+	return filetype.DetectReader(io.NewSectionReader(mergeFile, int64(dataFile.ChunkOffset), int64(dataFile.ChunkLength)))
+}
+
+// manifestFormat returns the format the manifest should be written in.
+// Unless it was explicitly specified, it's derived from the file extension.
+func (opts *ManifestOptions) manifestFormat() string {
+	if opts.ManifestFormat != "" {
+		return opts.ManifestFormat
+	}
+
+	if strings.EqualFold(filepath.Ext(string(opts.Manifest)), ".csv") {
+		return "csv"
+	}
+
+	return "json"
+}
+
+func (opts *ManifestOptions) write(m *manifest) error {
+	manifestPath := string(opts.Manifest)
+
+	f, err := os.Create(manifestPath)
+	if err != nil {
+		return fmt.Errorf("Can't create manifest %s: %v", manifestPath, err)
+	}
+	defer f.Close()
+
+	switch opts.manifestFormat() {
+	case "csv":
+		err = writeManifestCSV(f, m)
+	default:
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(m)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Can't write manifest %s: %v", manifestPath, err)
+	}
+
+	fmt.Printf("Wrote manifest with %d files to %s\n", len(m.Files), manifestPath)
+	return nil
+}
+
+func writeManifestCSV(w io.Writer, m *manifest) error {
+	hex := func(v uint32) string {
+		return "0x" + strings.ToUpper(strconv.FormatUint(uint64(v), 16))
+	}
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"path", "destination", "mergefile", "standalone", "chunk_offset", "chunk_length", "type", "sha1", "entry_address", "path_address", "mergefile_address"})
+	for _, e := range m.Files {
+		_ = cw.Write([]string{
+			e.Path,
+			e.Destination,
+			e.MergeFile,
+			strconv.FormatBool(e.Standalone),
+			hex(e.ChunkOffset),
+			strconv.FormatUint(uint64(e.ChunkLength), 10),
+			e.Type,
+			e.SHA1,
+			hex(e.EntryAddress),
+			hex(e.PathAddress),
+			hex(e.MergeFileAddress),
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
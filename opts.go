@@ -1,18 +1,198 @@
 package main
 
-import "github.com/jessevdk/go-flags"
+import (
+	"strconv"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// offsetFlag is a uint32 flag that also accepts hexadecimal values like 0x2CCF00.
+type offsetFlag uint32
+
+func (o *offsetFlag) UnmarshalFlag(value string) error {
+	parsed, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return err
+	}
+
+	*o = offsetFlag(parsed)
+	return nil
+}
+
+func (o offsetFlag) MarshalFlag() (string, error) {
+	return "0x" + strconv.FormatUint(uint64(o), 16), nil
+}
+
+type DebugOptions struct {
+	Debug bool `long:"debug" description:"Debug mode"`
+}
 
 type DefaultOptions struct {
-	Debug  bool `long:"debug" description:"Debug mode"`
+	DebugOptions
+
 	DryRun bool `long:"dry-run" description:"Skip file extraction"`
 }
 
+type InputOptions struct {
+	InFile  flags.Filename `long:"infile" short:"i" description:"The game's binary file (usually named something like SLUS_202.28)"`
+	ISOFile flags.Filename `long:"iso" description:"A disc image to read the game's files from directly"`
+
+	SkipISOHash bool           `long:"skip-iso-hash" description:"Don't hash the disc image, only identify the game by its binary"`
+	VersionFile flags.Filename `long:"versions" description:"A JSON file with additional game versions (versions.json in the sh2unpack config directory is loaded automatically)"`
+
+	DataOffset  offsetFlag `long:"data-offset" description:"File offset of the file tables, skips version detection"`
+	MagicOffset offsetFlag `long:"magic-offset" description:"Difference between virtual addresses and file offsets, derived from the ELF headers if omitted (requires --data-offset)"`
+}
+
+type FilterOptions struct {
+	Include      []string       `long:"include" description:"Only include files whose path matches this glob pattern, ** matches across directories (can be used multiple times)"`
+	Exclude      []string       `long:"exclude" description:"Exclude files whose path matches this glob pattern (can be used multiple times)"`
+	IncludeRegex []string       `long:"include-regex" description:"Only include files whose path matches this regular expression (can be used multiple times)"`
+	ExcludeRegex []string       `long:"exclude-regex" description:"Exclude files whose path matches this regular expression (can be used multiple times)"`
+	IncludeList  flags.Filename `long:"include-list" description:"Only include the paths listed in this file, one per line"`
+	BinaryFiles  bool           `long:"binary-files" description:"Also include standalone binary files (GX/GY/GZ files and such)"`
+}
+
+type ManifestOptions struct {
+	Manifest       flags.Filename `long:"manifest" description:"Write a manifest describing every file to this path"`
+	ManifestFormat string         `long:"manifest-format" choice:"json" choice:"csv" description:"Format of the manifest (default: derived from the file extension)"`
+}
+
 type UnpackOptions struct {
 	DefaultOptions
+	InputOptions
+	FilterOptions
+	ManifestOptions
 
-	InFile flags.Filename `long:"infile" short:"i" required:"true" description:"The game's binary file (usually named something like SLUS_202.28)"`
+	Jobs int `long:"jobs" short:"j" description:"Number of files to extract in parallel (default: number of CPUs)"`
+
+	GroupByType   bool `long:"group-by-type" description:"Put files into a subdirectory for each detected file type"`
+	FixExtensions bool `long:"fix-extensions" description:"Replace file extensions that don't match the detected file type"`
+	DemuxPSS      bool `long:"demux-pss" description:"Also split extracted PSS movies into .m2v video and .wav audio"`
+	DecodeVAG     bool `long:"decode-vag" description:"Also decode extracted VAG files to .wav"`
+	DecodeBGM     bool `long:"decode-bgm" description:"Also decode extracted SShd audio streams to .wav"`
 
 	Pos struct {
 		OutDir flags.Filename `positional-arg-name:"outdir" description:"The output directory"`
 	} `positional-args:"yes" required:"yes"`
 }
+
+type ListOptions struct {
+	DebugOptions
+	InputOptions
+	FilterOptions
+	ManifestOptions
+
+	Sort    string `long:"sort" choice:"table" choice:"path" choice:"mergefile" choice:"size" default:"table" description:"Sort order of the listing"`
+	Reverse bool   `long:"reverse" description:"Reverse the sort order"`
+}
+
+type RepackOptions struct {
+	DebugOptions
+	InputOptions
+
+	From  flags.Filename `long:"from" required:"true" description:"The folder with the extracted (and modified) files"`
+	Align offsetFlag     `long:"align" description:"Alignment of chunks within mergefiles (default: derived from the original layout)"`
+
+	Pos struct {
+		OutDir flags.Filename `positional-arg-name:"outdir" description:"The output directory for the rebuilt mergefiles and executable"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type PatchOptions struct {
+	DefaultOptions
+	InputOptions
+
+	Pos struct {
+		Path        string         `positional-arg-name:"path" description:"Path of the data file to replace, as shown by the list command"`
+		Replacement flags.Filename `positional-arg-name:"replacement" description:"The file to replace it with"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type OrphansOptions struct {
+	DebugOptions
+	InputOptions
+
+	Dump           flags.Filename `long:"dump" description:"Dump every orphaned range as an .orphan file into this directory"`
+	IncludePadding bool           `long:"include-padding" description:"Don't trim null bytes off orphaned ranges, report ranges that only contain null bytes"`
+}
+
+type VerifyOptions struct {
+	DebugOptions
+	InputOptions
+}
+
+type StatsOptions struct {
+	DebugOptions
+
+	SkipISOHash bool           `long:"skip-iso-hash" description:"Don't hash disc images, only identify the game by its binary"`
+	VersionFile flags.Filename `long:"versions" description:"A JSON file with additional game versions (versions.json in the sh2unpack config directory is loaded automatically)"`
+
+	Pos struct {
+		Inputs []flags.Filename `positional-arg-name:"input" description:"Game binaries or disc images (.iso) to gather statistics from" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type DiffOptions struct {
+	DebugOptions
+
+	SkipISOHash bool           `long:"skip-iso-hash" description:"Don't hash disc images, only identify the game by its binary"`
+	VersionFile flags.Filename `long:"versions" description:"A JSON file with additional game versions (versions.json in the sh2unpack config directory is loaded automatically)"`
+
+	FilterOptions
+
+	SortByPath bool `long:"sort-by-path" description:"Sort the differences by path instead of table order"`
+
+	Pos struct {
+		Old flags.Filename `positional-arg-name:"old" description:"The game binary or disc image (.iso) of the old version"`
+		New flags.Filename `positional-arg-name:"new" description:"The game binary or disc image (.iso) of the new version"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type ServeOptions struct {
+	DebugOptions
+	InputOptions
+
+	Listen string `long:"listen" default:"127.0.0.1:8080" description:"Address to listen on"`
+}
+
+type InspectOptions struct {
+	DebugOptions
+	InputOptions
+
+	Tables []string `long:"table" choice:"file-paths" choice:"entries" choice:"paths" description:"Only print this table (can be used multiple times, default: all tables)"`
+}
+
+type PSSOptions struct {
+	DebugOptions
+
+	OutDir flags.Filename `long:"outdir" short:"o" description:"Where to put the demuxed streams (default: next to the input file)"`
+
+	Pos struct {
+		Inputs []flags.Filename `positional-arg-name:"file" description:"PSS movies to demux" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type VAGOptions struct {
+	DebugOptions
+
+	SampleRate int            `long:"sample-rate" description:"Sample rate of headerless SPU ADPCM files, overrides the one in VAGp headers"`
+	OutDir     flags.Filename `long:"outdir" short:"o" description:"Where to put the WAV files (default: next to the input file)"`
+
+	Pos struct {
+		Inputs []flags.Filename `positional-arg-name:"file" description:"VAGp or headerless SPU ADPCM files to decode" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type BGMOptions struct {
+	DebugOptions
+
+	SampleRate int            `long:"sample-rate" description:"Sample rate (default: from the SShd header, or 48000)"`
+	Channels   int            `long:"channels" description:"Number of channels (default: from the SShd header, or 2)"`
+	Interleave offsetFlag     `long:"interleave" description:"Bytes per channel in turn, like 0x800 (default: from the SShd header, or detected)"`
+	OutDir     flags.Filename `long:"outdir" short:"o" description:"Where to put the WAV files (default: next to the input file)"`
+
+	Pos struct {
+		Inputs []flags.Filename `positional-arg-name:"file" description:"Interleaved SPU ADPCM streams to decode, with or without an SShd header" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
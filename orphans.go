@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"sh2unpack/utils"
+)
+
+// trimPadding shrinks a range of a file so it doesn't start or end with null bytes.
+// The returned range is empty if there's nothing but null bytes.
+func trimPadding(r io.ReaderAt, rng utils.Range) (utils.Range, error) {
+	buf := make([]byte, 0x10000)
+	trimmed := utils.Range{Start: -1, End: -1}
+
+	for pos := rng.Start; pos < rng.End; {
+		n := int(min(int64(len(buf)), rng.End-pos))
+		_, err := r.ReadAt(buf[:n], pos)
+		if err != nil {
+			return utils.Range{}, err
+		}
+
+		for i, b := range buf[:n] {
+			if b == 0 {
+				continue
+			}
+
+			if trimmed.Start < 0 {
+				trimmed.Start = pos + int64(i)
+			}
+			trimmed.End = pos + int64(i) + 1
+		}
+		pos += int64(n)
+	}
+
+	if trimmed.Start < 0 {
+		return utils.Range{}, nil
+	}
+
+	return trimmed, nil
+}
+
+func (opts *OrphansOptions) Execute(args []string) error {
+	dumpDirPath := string(opts.Dump)
+
+	input, err := opts.InputOptions.open(opts.Debug, false)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	input.printVersion()
+
+	dataMap := input.DataMap
+
+	dataFiles, err := dataMap.DataFiles()
+	if err != nil {
+		return fmt.Errorf("Can't resolve data files: %v", err)
+	}
+
+	// collect the referenced ranges of every mergefile, keeping the table order of the mergefiles
+	var mgfPaths []string
+	chunkRanges := map[string][]utils.Range{}
+	for _, dataFile := range dataFiles {
+		if _, ok := chunkRanges[dataFile.MergeFilePath]; !ok {
+			mgfPaths = append(mgfPaths, dataFile.MergeFilePath)
+		}
+
+		start := int64(dataFile.ChunkOffset)
+		chunkRanges[dataFile.MergeFilePath] = append(chunkRanges[dataFile.MergeFilePath], utils.Range{Start: start, End: start + int64(dataFile.ChunkLength)})
+	}
+
+	numOrphans := 0
+	var orphanedBytes int64
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "MERGEFILE\tOFFSET\tLENGTH")
+
+	for _, mgfPath := range mgfPaths {
+		mergeFile, err := input.OpenFile(mgfPath)
+		if err != nil {
+			return fmt.Errorf("Can't open mergefile: %v", err)
+		}
+
+		mgfSize, err := input.FileSize(mgfPath)
+		if err != nil {
+			return fmt.Errorf("Can't open mergefile: %v", err)
+		}
+
+		for _, gap := range utils.Gaps(chunkRanges[mgfPath], mgfSize) {
+			if !opts.IncludePadding {
+				// null bytes between chunks are just alignment padding
+				gap, err = trimPadding(mergeFile, gap)
+				if err != nil {
+					return fmt.Errorf("Can't read %s: %v", mgfPath, err)
+				}
+
+				if gap.Len() == 0 {
+					continue
+				}
+			}
+
+			_, _ = fmt.Fprintf(w, "%s\t0x%08X\t%d\n", mgfPath, gap.Start, gap.Len())
+			numOrphans++
+			orphanedBytes += gap.Len()
+
+			if dumpDirPath == "" {
+				continue
+			}
+
+			orphanPath := filepath.Join(dumpDirPath, fmt.Sprintf("%s_0x%08X.orphan", mgfPath, gap.Start))
+			err = os.MkdirAll(filepath.Dir(orphanPath), 0700)
+			if err != nil {
+				return fmt.Errorf("Can't create destination dir: %v", err)
+			}
+
+			f, err := os.Create(orphanPath)
+			if err != nil {
+				return fmt.Errorf("Can't create orphan file %s: %v", orphanPath, err)
+			}
+
+			err = utils.CopyPartOfFileToFile(f, mergeFile, gap.Start, gap.Len())
+			_ = f.Close()
+			if err != nil {
+				return fmt.Errorf("Can't copy orphaned data to %s: %v", orphanPath, err)
+			}
+		}
+	}
+	_ = w.Flush()
+
+	fmt.Printf("Found %d orphaned ranges totalling %d bytes in %d mergefiles.\n", numOrphans, orphanedBytes, len(mgfPaths))
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+func (opts *PatchOptions) Execute(args []string) error {
+	datPath := opts.Pos.Path
+	replacementPath := string(opts.Pos.Replacement)
+
+	replacement, err := os.ReadFile(replacementPath)
+	if err != nil {
+		return fmt.Errorf("Can't read replacement file: %v", err)
+	}
+
+	input, err := opts.InputOptions.open(opts.Debug, false)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	input.printVersion()
+
+	// the path is looked up the same way every other command does, ignoring case
+	target, err := input.Entry(datPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("No data file with the path %s", datPath)
+	} else if err != nil {
+		return fmt.Errorf("Can't resolve data files: %v", err)
+	}
+	datPath = target.Path
+
+	dataFiles, err := input.Entries()
+	if err != nil {
+		return fmt.Errorf("Can't resolve data files: %v", err)
+	}
+
+	if len(replacement) > int(target.ChunkLength) {
+		return fmt.Errorf("%s is %d bytes, but %s only has room for %d bytes. Use the repack command instead",
+			replacementPath, len(replacement), datPath, target.ChunkLength)
+	}
+
+	// other data files pointing at the same chunk are going to change as well
+	for _, dataFile := range dataFiles {
+		if dataFile.Path != target.Path && dataFile.MergeFilePath == target.MergeFilePath &&
+			dataFile.ChunkOffset == target.ChunkOffset && dataFile.ChunkLength == target.ChunkLength {
+			fmt.Printf("Note: %s shares its data with %s\n", dataFile.Path, datPath)
+		}
+	}
+
+	fmt.Printf("Patching %s in %s at 0x%X (%d of %d bytes, %d bytes of padding)\n",
+		datPath, target.MergeFilePath, target.ChunkOffset, len(replacement), target.ChunkLength, int(target.ChunkLength)-len(replacement))
+
+	if opts.DryRun {
+		fmt.Println("Doing a dry run.")
+		return nil
+	}
+
+	if input.ImagePath != "" {
+		fmt.Printf("Warning: this changes the disc image %s in place, there's no backup. Copy it first to keep the original.\n", input.ImagePath)
+	}
+
+	f, baseOffset, err := input.openForPatching(target.MergeFilePath)
+	if err != nil {
+		return fmt.Errorf("Can't open mergefile for writing: %v", err)
+	}
+	defer f.Close()
+
+	// the chunk keeps its length, the rest of it is zeroed out
+	padded := make([]byte, target.ChunkLength)
+	copy(padded, replacement)
+
+	_, err = f.WriteAt(padded, baseOffset+int64(target.ChunkOffset))
+	if err != nil {
+		return fmt.Errorf("Can't write to mergefile: %v", err)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return fmt.Errorf("Can't write to mergefile: %v", err)
+	}
+
+	fmt.Println("Done.")
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sh2unpack/audio"
+	"sh2unpack/pss"
+)
+
+// writeWAVFile writes decoded audio to a WAV file.
+func writeWAVFile(wavPath string, wav *audio.WAV) error {
+	f, err := os.Create(wavPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	_, err = wav.WriteTo(bw)
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// demuxPSS splits a PSS movie into basePath.m2v and basePath.wav.
+// The audio is decoded while the movie is read, so it's never held in memory as a whole.
+// Files for streams the movie doesn't have aren't created.
+func demuxPSS(r io.Reader, basePath string) (*pss.Stats, error) {
+	videoPath := basePath + ".m2v"
+	videoFile, err := os.Create(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	audioPath := basePath + ".wav"
+	audioFile, err := os.Create(audioPath)
+	if err != nil {
+		_ = videoFile.Close()
+		_ = os.Remove(videoPath)
+		return nil, err
+	}
+
+	audioWriter := audio.NewStreamWriter(audioFile)
+	bw := bufio.NewWriter(videoFile)
+	stats, err := pss.Demux(r, bw, audioWriter)
+	if err == nil {
+		err = bw.Flush()
+	}
+	_ = videoFile.Close()
+
+	if err == nil && stats.AudioBytes > 0 {
+		err = audioWriter.Close()
+		if err != nil {
+			err = fmt.Errorf("Can't decode audio: %v", err)
+		}
+	}
+	_ = audioFile.Close()
+
+	if err != nil {
+		// don't leave half-written files behind
+		_ = os.Remove(videoPath)
+		_ = os.Remove(audioPath)
+		return stats, err
+	}
+
+	if stats.VideoBytes == 0 {
+		_ = os.Remove(videoPath)
+	}
+
+	if stats.AudioBytes == 0 {
+		_ = os.Remove(audioPath)
+	}
+
+	return stats, nil
+}
+
+// trimExt returns p without its extension.
+func trimExt(p string) string {
+	return strings.TrimSuffix(p, filepath.Ext(p))
+}
+
+func (opts *PSSOptions) Execute(args []string) error {
+	for _, input := range opts.Pos.Inputs {
+		inPath := string(input)
+
+		outDir := string(opts.OutDir)
+		if outDir == "" {
+			outDir = filepath.Dir(inPath)
+		}
+
+		err := os.MkdirAll(outDir, 0700)
+		if err != nil {
+			return fmt.Errorf("Can't create output dir %s: %v", outDir, err)
+		}
+
+		f, err := os.Open(inPath)
+		if err != nil {
+			return fmt.Errorf("Can't open file: %v", err)
+		}
+
+		basePath := filepath.Join(outDir, trimExt(filepath.Base(inPath)))
+		stats, err := demuxPSS(bufio.NewReader(f), basePath)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("Can't demux %s: %v", inPath, err)
+		}
+
+		fmt.Printf("%s: %d bytes of video, %d bytes of audio\n", inPath, stats.VideoBytes, stats.AudioBytes)
+		if opts.Debug {
+			fmt.Printf("packets: %d, skipped bytes: %d, audio substream: %d\n", stats.Packets, stats.SkippedBytes, stats.AudioSubstream)
+		}
+	}
+
+	return nil
+}
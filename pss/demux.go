@@ -0,0 +1,258 @@
+// Package pss demuxes the PS2's PSS movies, which are MPEG-2 program streams
+// with the audio tucked away in private stream 1.
+package pss
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// stream IDs
+const (
+	packHeader     = 0xBA
+	systemHeader   = 0xBB
+	privateStream1 = 0xBD
+	programEnd     = 0xB9
+	firstVideo     = 0xE0
+)
+
+var (
+	ErrNotProgramStream = errors.New("not an MPEG program stream")
+	ErrMalformedPacket  = errors.New("malformed packet")
+)
+
+// Stats describes what Demux found.
+type Stats struct {
+	Packets      int
+	VideoBytes   int64
+	AudioBytes   int64
+	SkippedBytes int64 // garbage between packets
+
+	// AudioSubstream is the private stream 1 substream the audio was taken from, or -1 if there was no audio
+	AudioSubstream int
+}
+
+// demuxer keeps track of the state across packets.
+type demuxer struct {
+	r     *bufio.Reader
+	video io.Writer
+	audio io.Writer
+	stats Stats
+
+	// number of bytes at the start of each private stream 1 payload that belong to the substream header,
+	// derived from where the SShd header is in the first payload
+	audioHeaderSize int
+}
+
+// Demux splits an MPEG-2 program stream into its first video stream and its private stream 1 audio.
+// The audio is written as it's stored on the disc, starting with the SShd header, see audio.FindStreamHeader.
+// Only the substream the SShd header was found in is used, other substreams are ignored.
+func Demux(r io.Reader, video, audio io.Writer) (*Stats, error) {
+	d := &demuxer{
+		r:               bufio.NewReader(r),
+		video:           video,
+		audio:           audio,
+		stats:           Stats{AudioSubstream: -1},
+		audioHeaderSize: -1,
+	}
+
+	first := true
+	for {
+		streamID, err := d.nextStartCode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return &d.stats, err
+		}
+
+		if first && streamID != packHeader {
+			return &d.stats, ErrNotProgramStream
+		}
+		first = false
+
+		err = d.handle(streamID)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// truncated last packet, keep what we have
+			break
+		}
+		if err != nil {
+			return &d.stats, err
+		}
+	}
+
+	if first {
+		return &d.stats, ErrNotProgramStream
+	}
+
+	return &d.stats, nil
+}
+
+// nextStartCode finds the next 00 00 01 xx start code and returns xx.
+func (d *demuxer) nextStartCode() (byte, error) {
+	var window uint32
+	skipped := int64(-4)
+
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		window = window<<8 | uint32(b)
+		skipped++
+
+		if window&0xFFFFFF00 == 0x00000100 {
+			if skipped > 0 {
+				d.stats.SkippedBytes += skipped
+			}
+			return b, nil
+		}
+	}
+}
+
+func (d *demuxer) handle(streamID byte) error {
+	switch {
+	case streamID == packHeader:
+		return d.skipPackHeader()
+	case streamID == programEnd:
+		return nil
+	case streamID < systemHeader:
+		// not a packet, the start code scan will take care of it
+		return nil
+	}
+
+	var length uint16
+	err := binary.Read(d.r, binary.BigEndian, &length)
+	if err != nil {
+		return err
+	}
+
+	packet := make([]byte, length)
+	_, err = io.ReadFull(d.r, packet)
+	if err != nil {
+		return err
+	}
+	d.stats.Packets++
+
+	switch streamID {
+	case firstVideo:
+		payload, err := pesPayload(packet)
+		if err != nil {
+			return err
+		}
+
+		n, err := d.video.Write(payload)
+		d.stats.VideoBytes += int64(n)
+		return err
+	case privateStream1:
+		payload, err := pesPayload(packet)
+		if err != nil {
+			return err
+		}
+
+		return d.handleAudio(payload)
+	}
+
+	// system header, padding, private stream 2 and everything else
+	return nil
+}
+
+// handleAudio strips the substream header off a private stream 1 payload and writes the rest.
+func (d *demuxer) handleAudio(payload []byte) error {
+	if len(payload) == 0 {
+		return nil
+	}
+
+	if d.audioHeaderSize < 0 {
+		// wait for the payload containing the SShd header
+		i := bytes.Index(payload, []byte("SShd"))
+		if i < 0 {
+			return nil
+		}
+
+		d.audioHeaderSize = i
+		if i > 0 {
+			d.stats.AudioSubstream = int(payload[0])
+		} else {
+			d.stats.AudioSubstream = 0
+		}
+	} else if d.audioHeaderSize > 0 && int(payload[0]) != d.stats.AudioSubstream {
+		return nil
+	}
+
+	if len(payload) < d.audioHeaderSize {
+		return fmt.Errorf("%w: private stream payload of %d bytes", ErrMalformedPacket, len(payload))
+	}
+
+	n, err := d.audio.Write(payload[d.audioHeaderSize:])
+	d.stats.AudioBytes += int64(n)
+	return err
+}
+
+// skipPackHeader skips the rest of an MPEG-1 or MPEG-2 pack header.
+func (d *demuxer) skipPackHeader() error {
+	b, err := d.r.Peek(1)
+	if err != nil {
+		return err
+	}
+
+	if b[0]>>6 == 0x01 {
+		// MPEG-2: 10 bytes, the last 3 bits of which are the stuffing length
+		header := make([]byte, 10)
+		_, err := io.ReadFull(d.r, header)
+		if err != nil {
+			return err
+		}
+
+		_, err = d.r.Discard(int(header[9] & 0x07))
+		return err
+	}
+
+	// MPEG-1: 8 bytes
+	_, err = d.r.Discard(8)
+	return err
+}
+
+// pesPayload returns the payload of a PES packet, without the start code, stream ID and length.
+func pesPayload(packet []byte) ([]byte, error) {
+	if len(packet) >= 3 && packet[0]&0xC0 == 0x80 {
+		// MPEG-2
+		start := 3 + int(packet[2])
+		if start > len(packet) {
+			return nil, fmt.Errorf("%w: PES header longer than the packet", ErrMalformedPacket)
+		}
+		return packet[start:], nil
+	}
+
+	// MPEG-1: stuffing, then optional buffer size and timestamps
+	i := 0
+	for i < len(packet) && packet[i] == 0xFF {
+		i++
+	}
+
+	if i < len(packet) && packet[i]&0xC0 == 0x40 {
+		i += 2
+	}
+
+	if i < len(packet) {
+		switch packet[i] & 0xF0 {
+		case 0x20:
+			i += 5
+		case 0x30:
+			i += 10
+		default:
+			i++
+		}
+	}
+
+	if i > len(packet) {
+		return nil, fmt.Errorf("%w: PES header longer than the packet", ErrMalformedPacket)
+	}
+
+	return packet[i:], nil
+}
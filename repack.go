@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/exp/slices"
+	"sh2unpack/filetype"
+	"sh2unpack/sh2"
+	"sh2unpack/utils"
+)
+
+// maximum alignment derived from the original layout, that's one disc sector
+const maxDerivedAlignment = 0x800
+
+// repackChunk is a chunk of a mergefile that's about to be rewritten.
+// Multiple data files can share the same chunk.
+type repackChunk struct {
+	dataFiles   []sh2.DataFile
+	candidates  []string // paths of the files found for the chunk's data files
+	replacement string   // path of the replacement file, empty if the original data is kept
+
+	newOffset uint32
+	newLength uint32
+}
+
+// deriveAlignment returns the largest power of two (up to one sector) that all chunk offsets are divisible by.
+func deriveAlignment(dataFiles []sh2.DataFile) uint32 {
+	alignment := uint32(maxDerivedAlignment)
+	for _, dataFile := range dataFiles {
+		for alignment > 1 && dataFile.ChunkOffset%alignment != 0 {
+			alignment /= 2
+		}
+	}
+
+	return alignment
+}
+
+// typedPaths returns the paths unpack's --fix-extensions and --group-by-type can move a file of the given type to.
+func typedPaths(p string, fileType filetype.Type) []string {
+	fixed := filetype.FixExtension(p, fileType)
+	return []string{fixed, path.Join(fileType.Name, p), path.Join(fileType.Name, fixed)}
+}
+
+func isFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// findReplacement returns the path of the file in fromDirPath that replaces dataFile, or an empty string if there isn't one.
+// If the file isn't at the data file's path, the places --fix-extensions and --group-by-type would have put it are tried,
+// using the type of the original chunk just like unpack did. moved reports whether it was found in one of those.
+func findReplacement(fromDirPath string, dataFile sh2.DataFile, mergeFile io.ReaderAt) (replacementPath string, moved bool, err error) {
+	replacementPath = filepath.Join(fromDirPath, filepath.FromSlash(dataFile.Path))
+	if isFile(replacementPath) {
+		return replacementPath, false, nil
+	}
+
+	fileType, err := filetype.DetectReader(io.NewSectionReader(mergeFile, int64(dataFile.ChunkOffset), int64(dataFile.ChunkLength)))
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, p := range typedPaths(dataFile.Path, fileType) {
+		replacementPath = filepath.Join(fromDirPath, filepath.FromSlash(p))
+		if isFile(replacementPath) {
+			return replacementPath, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+func isEOF(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sameContents reports whether the file at p holds exactly what r does.
+func sameContents(p string, r io.Reader) (bool, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	bufA := make([]byte, 0x10000)
+	bufB := make([]byte, len(bufA))
+	for {
+		na, err := io.ReadFull(f, bufA)
+		if err != nil && !isEOF(err) {
+			return false, err
+		}
+
+		nb, err := io.ReadFull(r, bufB)
+		if err != nil && !isEOF(err) {
+			return false, err
+		}
+
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		if na < len(bufA) {
+			// both ended
+			return true, nil
+		}
+	}
+}
+
+// pickReplacement decides which of the chunk's candidates replaces it.
+// After a full unpack, every data file sharing a chunk has a file of its own, so the one that differs from the original chunk wins.
+// If several differ from it, they have to be identical, otherwise there's no telling which one is meant.
+func (chunk *repackChunk) pickReplacement(mergeFile io.ReaderAt) error {
+	if len(chunk.candidates) <= 1 {
+		chunk.replacement = ""
+		if len(chunk.candidates) == 1 {
+			chunk.replacement = chunk.candidates[0]
+		}
+		return nil
+	}
+
+	original := chunk.dataFiles[0]
+
+	var changed []string
+	for _, candidate := range chunk.candidates {
+		same, err := sameContents(candidate, io.NewSectionReader(mergeFile, int64(original.ChunkOffset), int64(original.ChunkLength)))
+		if err != nil {
+			return err
+		}
+
+		if !same {
+			changed = append(changed, candidate)
+		}
+	}
+
+	if len(changed) == 0 {
+		// they're all the original data
+		chunk.replacement = chunk.candidates[0]
+		return nil
+	}
+
+	for _, other := range changed[1:] {
+		f, err := os.Open(changed[0])
+		if err != nil {
+			return err
+		}
+
+		same, err := sameContents(other, f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+
+		if !same {
+			return fmt.Errorf("%s and %s share a chunk, but they were changed differently", changed[0], other)
+		}
+	}
+
+	chunk.replacement = changed[0]
+	return nil
+}
+
+func sortChunksByOffset(chunks []*repackChunk) {
+	slices.SortFunc(chunks, func(a, b *repackChunk) int {
+		return cmp.Compare(a.dataFiles[0].ChunkOffset, b.dataFiles[0].ChunkOffset)
+	})
+}
+
+func alignUp(v, alignment uint64) uint64 {
+	return (v + alignment - 1) / alignment * alignment
+}
+
+// layoutMergeFile decides where a mergefile's chunks go when they're laid out back to back and returns the new mergefile size.
+// Everything before the first chunk is kept as-is, gaps between chunks are dropped.
+// Nothing is written, so a layout that doesn't fit the tables' 32-bit offsets is caught before any file is touched.
+func layoutMergeFile(chunks []*repackChunk, alignment uint32) (uint64, error) {
+	var cursor uint64
+	if len(chunks) > 0 {
+		cursor = uint64(chunks[0].dataFiles[0].ChunkOffset)
+	}
+
+	for _, chunk := range chunks {
+		cursor = alignUp(cursor, uint64(alignment))
+
+		length := uint64(chunk.dataFiles[0].ChunkLength)
+		if chunk.replacement != "" {
+			info, err := os.Stat(chunk.replacement)
+			if err != nil {
+				return 0, err
+			}
+			length = uint64(info.Size())
+		}
+
+		if cursor+length > math.MaxUint32 {
+			return 0, fmt.Errorf("%s: mergefile would exceed 4 GiB", chunk.dataFiles[0].Path)
+		}
+
+		chunk.newOffset = uint32(cursor)
+		chunk.newLength = uint32(length)
+		cursor += length
+	}
+
+	return alignUp(cursor, uint64(alignment)), nil
+}
+
+// writeMergeFile writes a mergefile laid out by layoutMergeFile to dst.
+func writeMergeFile(dst io.WriterAt, src io.ReaderAt, chunks []*repackChunk) error {
+	if len(chunks) > 0 {
+		err := utils.CopyPartOfFileToFile(io.NewOffsetWriter(dst, 0), src, 0, int64(chunks[0].dataFiles[0].ChunkOffset))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, chunk := range chunks {
+		w := io.NewOffsetWriter(dst, int64(chunk.newOffset))
+
+		if chunk.replacement == "" {
+			original := chunk.dataFiles[0]
+			err := utils.CopyPartOfFileToFile(w, src, int64(original.ChunkOffset), int64(original.ChunkLength))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		f, err := os.Open(chunk.replacement)
+		if err != nil {
+			return err
+		}
+
+		// the replacement could have changed since the layout was decided, don't let it spill into the next chunk
+		n, err := io.Copy(w, io.LimitReader(f, int64(chunk.newLength)+1))
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+
+		if n != int64(chunk.newLength) {
+			return fmt.Errorf("%s changed while repacking", chunk.replacement)
+		}
+	}
+
+	return nil
+}
+
+func (opts *RepackOptions) Execute(args []string) error {
+	fromDirPath := string(opts.From)
+	outDirPath := string(opts.Pos.OutDir)
+
+	input, err := opts.InputOptions.open(opts.Debug, false)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	fmt.Printf("Input: %s\n", input.BinaryPath)
+	fmt.Printf("Modified Files: %s\n", fromDirPath)
+	fmt.Printf("Output Folder: %s\n", outDirPath)
+	input.printVersion()
+
+	dataMap := input.DataMap
+
+	dataFiles, err := dataMap.DataFiles()
+	if err != nil {
+		return fmt.Errorf("Can't resolve data files: %v", err)
+	}
+
+	alignment := uint32(opts.Align)
+	if alignment == 0 {
+		alignment = deriveAlignment(dataFiles)
+	}
+
+	if opts.Debug {
+		fmt.Printf("alignment: 0x%X\n", alignment)
+	}
+
+	// group the data files by mergefile and chunk, keeping the original order of both
+	var mgfPaths []string
+	mergeFiles := map[string]io.ReaderAt{}
+	chunkMap := map[string]map[[2]uint32]*repackChunk{}
+	chunkLists := map[string][]*repackChunk{}
+	numMoved := 0
+
+	for _, dataFile := range dataFiles {
+		mgfPath := dataFile.MergeFilePath
+		if _, ok := chunkMap[mgfPath]; !ok {
+			mgfPaths = append(mgfPaths, mgfPath)
+			chunkMap[mgfPath] = map[[2]uint32]*repackChunk{}
+
+			mergeFiles[mgfPath], err = input.OpenFile(mgfPath)
+			if err != nil {
+				return fmt.Errorf("Can't open mergefile: %v", err)
+			}
+		}
+
+		key := [2]uint32{dataFile.ChunkOffset, dataFile.ChunkLength}
+		chunk, ok := chunkMap[mgfPath][key]
+		if !ok {
+			chunk = &repackChunk{}
+			chunkMap[mgfPath][key] = chunk
+			chunkLists[mgfPath] = append(chunkLists[mgfPath], chunk)
+		}
+		chunk.dataFiles = append(chunk.dataFiles, dataFile)
+
+		replacementPath, moved, err := findReplacement(fromDirPath, dataFile, mergeFiles[mgfPath])
+		if err != nil {
+			return fmt.Errorf("Can't read %s from %s: %v", dataFile.Path, mgfPath, err)
+		}
+
+		if replacementPath == "" {
+			continue
+		}
+
+		if moved {
+			numMoved++
+			if opts.Debug {
+				fmt.Printf("%s: using %s\n", dataFile.Path, replacementPath)
+			}
+		}
+
+		chunk.candidates = append(chunk.candidates, replacementPath)
+	}
+
+	if numMoved > 0 {
+		fmt.Printf("Warning: %d files weren't at their own paths, using the ones --group-by-type or --fix-extensions moved them to.\n", numMoved)
+	}
+
+	// pick the replacements and lay out every mergefile first, so nothing is written if one of them can't be repacked
+	numReplaced := 0
+	newSizes := map[string]uint64{}
+	for _, mgfPath := range mgfPaths {
+		chunks := chunkLists[mgfPath]
+		sortChunksByOffset(chunks)
+
+		for _, chunk := range chunks {
+			err := chunk.pickReplacement(mergeFiles[mgfPath])
+			if err != nil {
+				return fmt.Errorf("Can't pick a replacement for a chunk of %s: %v", mgfPath, err)
+			}
+
+			if chunk.replacement != "" {
+				numReplaced++
+			}
+		}
+
+		newSizes[mgfPath], err = layoutMergeFile(chunks, alignment)
+		if err != nil {
+			return fmt.Errorf("Can't lay out mergefile %s: %v", mgfPath, err)
+		}
+	}
+
+	// patch the executable so its data file entries point at the new layout
+	exe, err := io.ReadAll(io.NewSectionReader(input.Binary(), 0, math.MaxInt64))
+	if err != nil {
+		return fmt.Errorf("Can't read executable: %v", err)
+	}
+
+	for _, mgfPath := range mgfPaths {
+		for _, chunk := range chunkLists[mgfPath] {
+			for _, dataFile := range chunk.dataFiles {
+				entryOffset, ok := dataMap.FileOffset(dataFile.EntryAddress)
+				if !ok || int(entryOffset)+16 > len(exe) {
+					return fmt.Errorf("Can't find data file entry for %s at 0x%X", dataFile.Path, dataFile.EntryAddress)
+				}
+
+				// the entry type comes first, then EntryAddress, ChunkOffset, ChunkLength
+				binary.LittleEndian.PutUint32(exe[entryOffset+8:], chunk.newOffset)
+				binary.LittleEndian.PutUint32(exe[entryOffset+12:], chunk.newLength)
+			}
+		}
+	}
+
+	for _, mgfPath := range mgfPaths {
+		chunks := chunkLists[mgfPath]
+		newSize := newSizes[mgfPath]
+
+		destinationPath := filepath.Join(outDirPath, filepath.FromSlash(sh2.DiscPath(mgfPath)))
+		err := os.MkdirAll(filepath.Dir(destinationPath), 0700)
+		if err != nil {
+			return fmt.Errorf("Can't create destination dir: %v", err)
+		}
+
+		f, err := os.Create(destinationPath)
+		if err != nil {
+			return fmt.Errorf("Can't create mergefile %s: %v", destinationPath, err)
+		}
+
+		err = writeMergeFile(f, mergeFiles[mgfPath], chunks)
+		if err == nil {
+			err = f.Truncate(int64(newSize))
+		}
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("Can't write mergefile %s: %v", destinationPath, err)
+		}
+
+		if opts.Debug {
+			oldSize, _ := input.FileSize(mgfPath)
+			fmt.Printf("%s: %d -> %d bytes\n", mgfPath, oldSize, newSize)
+		}
+	}
+
+	exePath := filepath.Join(outDirPath, filepath.Base(input.BinaryPath))
+
+	err = os.WriteFile(exePath, exe, 0644)
+	if err != nil {
+		return fmt.Errorf("Can't write executable %s: %v", exePath, err)
+	}
+
+	if numReplaced == 0 {
+		fmt.Println("No files were found in the modified files folder, the original data was used for everything.")
+	}
+
+	fmt.Printf("Repacked %d mergefiles using %d files from %s.\n", len(mgfPaths), numReplaced, fromDirPath)
+	fmt.Printf("Patched executable: %s\n", exePath)
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"time"
+
+	"sh2unpack/filetype"
+	"sh2unpack/sh2"
+)
+
+// how many bytes of a file the file page shows
+const hexPreviewSize = 0x200
+
+var serveTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"hex": func(v uint32) string { return fmt.Sprintf("0x%08X", v) },
+}).Parse(`
+{{define "header"}}<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} – sh2unpack</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.2em 1em 0.2em 0; }
+td.num { text-align: right; font-family: monospace; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<p><a href="/browse/">{{.Version}}</a></p>
+<h1>{{.Title}}</h1>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "dir"}}{{template "header" .}}
+{{if .Parent}}<p><a href="/browse/{{.Parent}}">..</a></p>{{end}}
+<table>
+<tr><th>Name</th><th>Size</th><th>Mergefile</th><th>Offset</th></tr>
+{{range .Entries}}<tr>
+{{if .IsDir}}<td><a href="/browse/{{.Path}}/">{{.Name}}/</a></td><td></td><td></td><td></td>
+{{else}}<td><a href="/browse/{{.Path}}">{{.Name}}</a></td><td class="num">{{.DataFile.ChunkLength}}</td>
+<td>{{if .DataFile.Standalone}}(standalone){{else}}{{.DataFile.MergeFilePath}}{{end}}</td><td class="num">{{hex .DataFile.ChunkOffset}}</td>
+{{end}}</tr>
+{{end}}</table>
+{{template "footer" .}}{{end}}
+
+{{define "file"}}{{template "header" .}}
+<p><a href="/browse/{{.Parent}}">..</a> · <a href="/raw/{{.Path}}">Open</a> · <a href="/raw/{{.Path}}?download=1">Download</a></p>
+<table>
+<tr><th>Mergefile</th><td>{{if .DataFile.Standalone}}(standalone){{else}}{{.DataFile.MergeFilePath}}{{end}}</td></tr>
+<tr><th>Offset</th><td>{{hex .DataFile.ChunkOffset}}</td></tr>
+<tr><th>Length</th><td>{{.DataFile.ChunkLength}} bytes</td></tr>
+<tr><th>Type</th><td>{{.FileType.Description}}</td></tr>
+<tr><th>Entry address</th><td>{{hex .DataFile.EntryAddress}}</td></tr>
+<tr><th>Path address</th><td>{{hex .DataFile.PathAddress}}</td></tr>
+{{if not .DataFile.Standalone}}<tr><th>Mergefile entry address</th><td>{{hex .DataFile.MergeFileAddress}}</td></tr>{{end}}
+</table>
+<h2>First {{len .Preview}} bytes</h2>
+<pre>{{.HexDump}}</pre>
+{{template "footer" .}}{{end}}
+`))
+
+// serveEntry is a row of a directory listing.
+type serveEntry struct {
+	Name     string
+	Path     string
+	IsDir    bool
+	DataFile sh2.DataFile
+}
+
+// servePage holds everything the templates need.
+type servePage struct {
+	Title   string
+	Version string
+	Path    string
+	Parent  string
+
+	Entries []serveEntry
+
+	DataFile sh2.DataFile
+	FileType filetype.Type
+	Preview  []byte
+	HexDump  string
+}
+
+// assetServer serves an Archive's virtual file tree.
+type assetServer struct {
+	archive *sh2.Archive
+	debug   bool
+}
+
+// fsPath turns the rest of a request's URL path into a path the Archive accepts.
+func fsPath(p string) string {
+	p = path.Clean("/" + p)[1:]
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func (s *assetServer) newPage(p string) servePage {
+	page := servePage{
+		Title:   p,
+		Version: fmt.Sprintf("%s, %s", s.archive.Version.FileName, s.archive.Version.Description),
+		Path:    p,
+	}
+
+	if p != "." {
+		page.Parent = path.Dir(p)
+		if page.Parent == "." {
+			page.Parent = ""
+		} else {
+			page.Parent += "/"
+		}
+	}
+
+	return page
+}
+
+func (s *assetServer) error(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, fs.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *assetServer) render(w http.ResponseWriter, name string, page servePage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := serveTemplates.ExecuteTemplate(w, name, page)
+	if err != nil && s.debug {
+		fmt.Printf("Can't render %s: %v\n", page.Path, err)
+	}
+}
+
+func (s *assetServer) browse(w http.ResponseWriter, r *http.Request) {
+	p := fsPath(r.PathValue("path"))
+
+	info, err := s.archive.Stat(p)
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+
+	page := s.newPage(p)
+
+	if !info.IsDir() {
+		s.browseFile(w, page, info.Sys().(sh2.DataFile))
+		return
+	}
+
+	dirEntries, err := s.archive.ReadDir(p)
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+
+	if p == "." {
+		page.Title = "/"
+	}
+
+	for _, dirEntry := range dirEntries {
+		entry := serveEntry{
+			Name:  dirEntry.Name(),
+			Path:  path.Join(page.Path, dirEntry.Name()),
+			IsDir: dirEntry.IsDir(),
+		}
+
+		if !entry.IsDir {
+			info, err := dirEntry.Info()
+			if err != nil {
+				s.error(w, err)
+				return
+			}
+			entry.DataFile = info.Sys().(sh2.DataFile)
+		}
+
+		page.Entries = append(page.Entries, entry)
+	}
+
+	s.render(w, "dir", page)
+}
+
+func (s *assetServer) browseFile(w http.ResponseWriter, page servePage, dataFile sh2.DataFile) {
+	sr, err := s.archive.SectionReader(page.Path)
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+
+	preview := make([]byte, min(hexPreviewSize, sr.Size()))
+	_, err = io.ReadFull(sr, preview)
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+
+	page.DataFile = dataFile
+	page.FileType = filetype.Detect(preview)
+	page.Preview = preview
+	page.HexDump = hex.Dump(preview)
+
+	s.render(w, "file", page)
+}
+
+func (s *assetServer) raw(w http.ResponseWriter, r *http.Request) {
+	p := fsPath(r.PathValue("path"))
+
+	sr, err := s.archive.SectionReader(p)
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+
+	if r.URL.Query().Has("download") {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(p)))
+	}
+
+	// the content type is sniffed from the file name or, failing that, the contents
+	http.ServeContent(w, r, path.Base(p), time.Time{}, sr)
+}
+
+func (opts *ServeOptions) Execute(args []string) error {
+	input, err := opts.InputOptions.open(opts.Debug, false)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	input.printVersion()
+
+	// make sure the tables resolve before the server starts
+	_, err = input.Entries()
+	if err != nil {
+		return fmt.Errorf("Can't resolve data files: %v", err)
+	}
+
+	s := &assetServer{archive: input.Archive, debug: opts.Debug}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /browse/{path...}", s.browse)
+	mux.HandleFunc("GET /raw/{path...}", s.raw)
+	mux.Handle("GET /{$}", http.RedirectHandler("/browse/", http.StatusFound))
+
+	fmt.Printf("Serving on http://%s/\n", opts.Listen)
+	return http.ListenAndServe(opts.Listen, mux)
+}
@@ -0,0 +1,480 @@
+package sh2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+	"sh2unpack/iso"
+	"sh2unpack/utils"
+)
+
+// DefaultMinConfidence is the confidence discovered tables or tables at manual offsets need to have to be used.
+const DefaultMinConfidence = 0.9
+
+var (
+	ErrUnknownVersion = errors.New("unknown version of the game")
+	ErrLowConfidence  = errors.New("not confident enough in the file tables")
+	ErrNotSeekable    = errors.New("file is not seekable")
+)
+
+// Identification describes how an Archive's game version was determined.
+type Identification int
+
+const (
+	IdentifiedByManualOffsets Identification = iota
+	IdentifiedByISOHash
+	IdentifiedByBinaryHash
+	IdentifiedByDiscovery
+)
+
+func (i Identification) String() string {
+	switch i {
+	case IdentifiedByManualOffsets:
+		return "manual offsets"
+	case IdentifiedByISOHash:
+		return "disc image hash"
+	case IdentifiedByBinaryHash:
+		return "binary hash"
+	case IdentifiedByDiscovery:
+		return "discovered tables"
+	}
+
+	return fmt.Sprintf("Identification(%d)", int(i))
+}
+
+// ArchiveOptions control how an Archive identifies the game.
+type ArchiveOptions struct {
+	// DataOffset and MagicOffset skip version detection if DataOffset is non-zero.
+	// MagicOffset is derived from the ELF headers if it's zero, otherwise the headers are ignored.
+	DataOffset  uint32
+	MagicOffset uint32
+
+	// SkipISOHash skips hashing the disc image, the game is identified by its binary only.
+	SkipISOHash bool
+
+	// BinaryFiles also makes the standalone binary files (GX/GY/GZ files and such) entries of the Archive.
+	// Without it, only the data files inside the mergefiles are.
+	BinaryFiles bool
+
+	// MinConfidence overrides DefaultMinConfidence if non-zero.
+	MinConfidence float64
+
+	Debug bool
+
+	// Logf receives progress messages, like when a disc image is being hashed. Can be nil.
+	Logf func(format string, a ...any)
+}
+
+func (o ArchiveOptions) logf(format string, a ...any) {
+	if o.Logf != nil {
+		o.Logf(format, a...)
+	}
+}
+
+func (o ArchiveOptions) minConfidence() float64 {
+	if o.MinConfidence != 0 {
+		return o.MinConfidence
+	}
+	return DefaultMinConfidence
+}
+
+// Archive is an identified copy of the game, either a folder with the disc's files or a disc image.
+// Its entries are the data files inside the mergefiles, plus the standalone binary files if ArchiveOptions.BinaryFiles is set,
+// all addressable by path.
+// It implements fs.FS, fs.ReadDirFS and fs.StatFS, with directories made up from the entries' paths.
+// Once opened, an Archive only reads through ReadAt, so it's safe for concurrent use
+// as long as nobody seeks the shared handles returned by Binary and OpenFile.
+type Archive struct {
+	Version      GameVersion
+	IdentifiedBy Identification
+	DataMap      *DataMap
+
+	// ISOHash is the disc image's SHA1 hash, if it was hashed
+	ISOHash string
+
+	// BinaryPath is the path of the game's binary, either on disk or within the disc image
+	BinaryPath string
+
+	// FS is the file system the mergefiles are read from, either the binary's folder or the disc image
+	FS fs.FS
+
+	binary utils.ReadSeekerAt
+	image  utils.ReadSeekerAt
+
+	logf        func(format string, a ...any)
+	binaryFiles bool
+	indexOnce   sync.Once
+	indexErr    error
+	entries     []DataFile
+	entryIndex  map[string]int
+	root        *archiveNode
+
+	mu        sync.Mutex
+	openFiles map[string]utils.ReadSeekerAt
+	closers   []io.Closer
+}
+
+// OpenGameFolder opens the game's binary and reads the mergefiles from the folder it's in.
+func OpenGameFolder(binaryPath string, opts ArchiveOptions) (*Archive, error) {
+	binFile, err := os.Open(binaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't open game binary: %w", err)
+	}
+
+	a := &Archive{
+		BinaryPath: binaryPath,
+		FS:         os.DirFS(filepath.Dir(binaryPath)),
+		binary:     binFile,
+		closers:    []io.Closer{binFile},
+	}
+
+	err = a.load(opts)
+	if err != nil {
+		_ = a.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// OpenDiscImage opens a disc image and finds the game's binary using the disc's SYSTEM.CNF.
+func OpenDiscImage(isoPath string, opts ArchiveOptions) (*Archive, error) {
+	isoFile, err := os.Open(isoPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't open disc image: %w", err)
+	}
+
+	image, err := iso.Open(isoFile)
+	if err != nil {
+		_ = isoFile.Close()
+		return nil, fmt.Errorf("can't read disc image: %w", err)
+	}
+
+	exePath, err := FindExecutable(image)
+	if err != nil {
+		_ = isoFile.Close()
+		return nil, err
+	}
+
+	exeFile, err := image.Open(exePath)
+	if err != nil {
+		_ = isoFile.Close()
+		return nil, fmt.Errorf("can't open executable in disc image: %w", err)
+	}
+
+	opts.logf("Disc Image: %s (%s)", isoPath, image.VolumeID)
+
+	a := &Archive{
+		BinaryPath: exePath,
+		FS:         image,
+		binary:     exeFile.(*iso.File),
+		image:      isoFile,
+		closers:    []io.Closer{isoFile},
+	}
+
+	err = a.load(opts)
+	if err != nil {
+		_ = a.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// load identifies the game and reads its data map.
+func (a *Archive) load(opts ArchiveOptions) error {
+	err := a.identify(opts)
+	if err != nil {
+		return err
+	}
+
+	if a.IdentifiedBy == IdentifiedByManualOffsets && opts.MagicOffset != 0 {
+		a.DataMap, err = ReadDataMapWithMagicOffset(a.binary, opts.DataOffset, opts.MagicOffset, opts.Debug)
+	} else {
+		a.DataMap, err = ReadDataMap(a.binary, a.Version, opts.Debug)
+	}
+	if err != nil {
+		return fmt.Errorf("can't read data map: %w", err)
+	}
+
+	if a.IdentifiedBy == IdentifiedByManualOffsets {
+		// manual offsets are a shot in the dark, make sure the tables look sane before going any further
+		confidence := a.DataMap.Confidence()
+		if opts.Debug {
+			opts.logf("confidence: %.1f%%", confidence*100)
+		}
+
+		if confidence < opts.minConfidence() {
+			return fmt.Errorf("%w: tables at data offset 0x%X (confidence: %.1f%%)", ErrLowConfidence, a.Version.DataOffset, confidence*100)
+		}
+	}
+
+	// entries are indexed on first use, so broken tables can still be inspected through DataMap
+	a.logf = opts.logf
+	a.binaryFiles = opts.BinaryFiles
+	return nil
+}
+
+// identify figures out which version of the game we're dealing with.
+// Manual offsets take precedence over everything else.
+// If the game was read from a disc image, the image's hash is checked against the known redump entries first.
+// Failing that, the hash of the binary is used, and if that's unknown too, the binary is searched for the tables.
+func (a *Archive) identify(opts ArchiveOptions) error {
+	if opts.DataOffset != 0 {
+		a.IdentifiedBy = IdentifiedByManualOffsets
+		a.Version = GameVersion{
+			DataOffset:  opts.DataOffset,
+			FileName:    path.Base(filepath.ToSlash(a.BinaryPath)),
+			Description: "Unknown version (manual offsets)",
+		}
+		return nil
+	}
+
+	if a.image != nil && !opts.SkipISOHash {
+		opts.logf("Hashing disc image…")
+
+		isoHash, err := utils.HashFileSHA1(a.image)
+		if err != nil {
+			return fmt.Errorf("can't hash disc image: %w", err)
+		}
+		a.ISOHash = isoHash
+
+		gameVersion, ok := VersionFromISOHash(isoHash)
+		if ok {
+			opts.logf("Disc image matches redump entry: %s, %s (%s)", gameVersion.FileName, gameVersion.Description, isoHash)
+			a.IdentifiedBy = IdentifiedByISOHash
+			a.Version = gameVersion
+			return nil
+		}
+
+		opts.logf("Disc image doesn't match any known redump entry (%s), it might be modified or a bad dump", isoHash)
+	}
+
+	shaString, err := utils.HashFileSHA1(a.binary)
+	if err != nil {
+		return fmt.Errorf("can't hash game binary: %w", err)
+	}
+
+	gameVersion, ok := VersionMap[shaString]
+	if ok {
+		a.IdentifiedBy = IdentifiedByBinaryHash
+		a.Version = gameVersion
+		return nil
+	}
+
+	opts.logf("Unknown version of the game, searching for the file tables…")
+
+	discovery, err := DiscoverTables(a.binary)
+	if err != nil {
+		return fmt.Errorf("%w: %s (%w)", ErrUnknownVersion, a.BinaryPath, err)
+	}
+
+	opts.logf("Found %d file-path entries and %d typed entries at 0x%X (magic offset: 0x%X, confidence: %.1f%%)",
+		discovery.FilePathEntries, discovery.TypedEntries, discovery.DataOffset, discovery.MagicOffset, discovery.Confidence*100)
+
+	if discovery.Confidence < opts.minConfidence() {
+		return fmt.Errorf("%w: discovered tables in %s (confidence: %.1f%%)", ErrLowConfidence, a.BinaryPath, discovery.Confidence*100)
+	}
+
+	a.IdentifiedBy = IdentifiedByDiscovery
+	a.Version = GameVersion{
+		DataOffset:  discovery.DataOffset,
+		FileName:    path.Base(filepath.ToSlash(a.BinaryPath)),
+		Description: "Unknown version (discovered tables)",
+	}
+	return nil
+}
+
+// indexEntries resolves every data file, and the binary files if they were asked for, and makes them addressable by path.
+// Binary files that aren't there are skipped.
+func (a *Archive) indexEntries() error {
+	dataFiles, err := a.DataMap.DataFiles()
+	if err != nil {
+		return err
+	}
+
+	a.entries = dataFiles
+	err = a.indexBinaryFiles()
+	if err != nil {
+		return err
+	}
+
+	a.entryIndex = map[string]int{}
+	for i, entry := range a.entries {
+		key := entryKey(entry.Path)
+		// paths listed more than once point to the same chunk in practice, only keep the first
+		if _, ok := a.entryIndex[key]; !ok {
+			a.entryIndex[key] = i
+		}
+	}
+
+	a.root = buildTree(a.entries)
+
+	return nil
+}
+
+// indexBinaryFiles appends the binary files to the entries if ArchiveOptions.BinaryFiles was set.
+func (a *Archive) indexBinaryFiles() error {
+	if !a.binaryFiles {
+		return nil
+	}
+
+	binaryFiles, err := a.DataMap.BinaryFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, binaryFile := range binaryFiles {
+		size, err := a.FileSize(binaryFile.Path)
+		if err != nil {
+			a.logf("Skipping binary file %s: %v", binaryFile.Path, err)
+			continue
+		}
+
+		a.entries = append(a.entries, DataFile{
+			Path:          binaryFile.Path,
+			MergeFilePath: binaryFile.Path,
+			ChunkLength:   uint32(size),
+			Standalone:    true,
+			EntryAddress:  binaryFile.EntryAddress,
+			PathAddress:   binaryFile.PathAddress,
+		})
+	}
+
+	return nil
+}
+
+// entryKey normalizes an entry path for lookups. Paths are matched case-insensitively.
+func entryKey(p string) string {
+	return strings.ToLower(cleanEntryPath(p))
+}
+
+// Binary returns the game's binary. The handle is shared, so seeking it affects other users.
+func (a *Archive) Binary() utils.ReadSeekerAt {
+	return a.binary
+}
+
+// index indexes the entries once and returns the error that came up doing so, if any.
+func (a *Archive) index() error {
+	a.indexOnce.Do(func() {
+		a.indexErr = a.indexEntries()
+	})
+	return a.indexErr
+}
+
+// Entries returns every data file followed by every binary file, in table order.
+// The error wraps ErrUnresolvableEntry if the tables reference something that isn't there.
+func (a *Archive) Entries() ([]DataFile, error) {
+	if err := a.index(); err != nil {
+		return nil, err
+	}
+
+	return slices.Clone(a.entries), nil
+}
+
+// Entry returns the entry with the given path.
+// If there's no such entry, the error is an *fs.PathError wrapping fs.ErrNotExist.
+func (a *Archive) Entry(p string) (DataFile, error) {
+	if err := a.index(); err != nil {
+		return DataFile{}, err
+	}
+
+	i, ok := a.entryIndex[entryKey(p)]
+	if !ok {
+		return DataFile{}, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+
+	return a.entries[i], nil
+}
+
+// SectionReader returns a reader for the contents of the entry with the given path.
+func (a *Archive) SectionReader(p string) (*io.SectionReader, error) {
+	entry, err := a.Entry(p)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := a.OpenFile(entry.MergeFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NewSectionReader(f, int64(entry.ChunkOffset), int64(entry.ChunkLength)), nil
+}
+
+// OpenEntry returns the contents of the entry with the given path.
+// Closing it doesn't close the underlying mergefile, that's up to Archive.Close.
+func (a *Archive) OpenEntry(p string) (io.ReadCloser, error) {
+	sr, err := a.SectionReader(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(sr), nil
+}
+
+// OpenFile opens a file referenced by the path table, like a mergefile.
+// Files stay open until Close is called, opening the same file twice returns the same handle.
+func (a *Archive) OpenFile(p string) (utils.ReadSeekerAt, error) {
+	discPath := DiscPath(p)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if f, ok := a.openFiles[discPath]; ok {
+		return f, nil
+	}
+
+	f, err := a.FS.Open(discPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rsa, ok := f.(utils.ReadSeekerAt)
+	if !ok {
+		_ = f.Close()
+		return nil, &fs.PathError{Op: "open", Path: p, Err: ErrNotSeekable}
+	}
+
+	if a.openFiles == nil {
+		a.openFiles = map[string]utils.ReadSeekerAt{}
+	}
+
+	a.openFiles[discPath] = rsa
+	a.closers = append(a.closers, f)
+	return rsa, nil
+}
+
+// FileSize returns the size of a file referenced by the path table, without opening it.
+func (a *Archive) FileSize(p string) (int64, error) {
+	info, err := fs.Stat(a.FS, DiscPath(p))
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// Close closes every file opened by the Archive.
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// close in reverse order, the disc image has to outlive the files opened from it
+	var errs []error
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		errs = append(errs, a.closers[i].Close())
+	}
+	a.closers = nil
+	a.openFiles = nil
+
+	return errors.Join(errs...)
+}
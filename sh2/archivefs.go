@@ -0,0 +1,265 @@
+package sh2
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// archiveNode is a file or directory in an Archive's virtual file tree.
+type archiveNode struct {
+	name     string
+	isDir    bool
+	entry    DataFile // only set for files
+	children []*archiveNode
+}
+
+func (n *archiveNode) child(name string) *archiveNode {
+	for _, child := range n.children {
+		if strings.EqualFold(child.name, name) {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// cleanEntryPath turns an entry's path into something fs.ValidPath accepts, like data/chr/maria.bin.
+func cleanEntryPath(p string) string {
+	return path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))[1:]
+}
+
+// buildTree arranges the entries into a directory tree, creating directories as needed.
+// Like everywhere else, only the first entry for a path is used.
+func buildTree(entries []DataFile) *archiveNode {
+	root := &archiveNode{name: ".", isDir: true}
+
+	for _, entry := range entries {
+		p := cleanEntryPath(entry.Path)
+		if p == "" {
+			continue
+		}
+
+		parts := strings.Split(p, "/")
+		dir := root
+		for _, part := range parts[:len(parts)-1] {
+			next := dir.child(part)
+			if next == nil {
+				next = &archiveNode{name: part, isDir: true}
+				dir.children = append(dir.children, next)
+			}
+
+			dir = next
+			if !dir.isDir {
+				// there's a file in the way
+				break
+			}
+		}
+
+		name := parts[len(parts)-1]
+		if !dir.isDir || dir.child(name) != nil {
+			continue
+		}
+
+		dir.children = append(dir.children, &archiveNode{name: name, entry: entry})
+	}
+
+	sortTree(root)
+	return root
+}
+
+// sortTree sorts every directory's children by name, like fs.ReadDirFS wants them.
+func sortTree(n *archiveNode) {
+	slices.SortFunc(n.children, func(a, b *archiveNode) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	for _, child := range n.children {
+		if child.isDir {
+			sortTree(child)
+		}
+	}
+}
+
+// lookup resolves a slash-separated path in the virtual file tree. Names are matched case-insensitively.
+func (a *Archive) lookup(op, name string) (*archiveNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	if err := a.index(); err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	n := a.root
+	if name == "." {
+		return n, nil
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		n = n.child(part)
+		if n == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+
+	return n, nil
+}
+
+// Open implements fs.FS. Directories are made up from the entries' paths,
+// files are read from their mergefiles on demand.
+func (a *Archive) Open(name string) (fs.File, error) {
+	n, err := a.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.isDir {
+		return &archiveDir{node: n}, nil
+	}
+
+	f, err := a.OpenFile(n.entry.MergeFilePath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &archiveFile{
+		SectionReader: io.NewSectionReader(f, int64(n.entry.ChunkOffset), int64(n.entry.ChunkLength)),
+		node:          n,
+	}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (a *Archive) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := a.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !n.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries := make([]fs.DirEntry, len(n.children))
+	for i, child := range n.children {
+		entries[i] = archiveFileInfo{child}
+	}
+
+	return entries, nil
+}
+
+// Stat implements fs.StatFS.
+func (a *Archive) Stat(name string) (fs.FileInfo, error) {
+	n, err := a.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return archiveFileInfo{n}, nil
+}
+
+// archiveFile is an open entry. It also implements io.ReaderAt and io.Seeker.
+type archiveFile struct {
+	*io.SectionReader
+	node *archiveNode
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) {
+	return archiveFileInfo{f.node}, nil
+}
+
+// Close does nothing, the mergefile stays open until Archive.Close is called.
+func (f *archiveFile) Close() error {
+	return nil
+}
+
+// archiveDir is an open directory of the virtual file tree.
+type archiveDir struct {
+	node   *archiveNode
+	dirPos int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) {
+	return archiveFileInfo{d.node}, nil
+}
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: errors.New("is a directory")}
+}
+
+func (d *archiveDir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.node.children[d.dirPos:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > 0 && n < len(remaining) {
+		remaining = remaining[:n]
+	}
+
+	entries := make([]fs.DirEntry, len(remaining))
+	for i, child := range remaining {
+		entries[i] = archiveFileInfo{child}
+	}
+	d.dirPos += len(remaining)
+
+	return entries, nil
+}
+
+// archiveFileInfo implements both fs.FileInfo and fs.DirEntry.
+// For files, Sys returns the DataFile the file was made from.
+type archiveFileInfo struct {
+	n *archiveNode
+}
+
+func (fi archiveFileInfo) Name() string {
+	return fi.n.name
+}
+
+func (fi archiveFileInfo) Size() int64 {
+	if fi.n.isDir {
+		return 0
+	}
+	return int64(fi.n.entry.ChunkLength)
+}
+
+func (fi archiveFileInfo) Mode() fs.FileMode {
+	if fi.n.isDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// ModTime returns the zero time, the tables don't keep track of that.
+func (fi archiveFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (fi archiveFileInfo) IsDir() bool {
+	return fi.n.isDir
+}
+
+func (fi archiveFileInfo) Sys() any {
+	if fi.n.isDir {
+		return nil
+	}
+	return fi.n.entry
+}
+
+func (fi archiveFileInfo) Type() fs.FileMode {
+	return fi.Mode().Type()
+}
+
+func (fi archiveFileInfo) Info() (fs.FileInfo, error) {
+	return fi, nil
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 
 	"sh2unpack/utils"
 )
@@ -18,7 +17,7 @@ import (
 	Data Table Len: 0x032DE0 ( 208352)
 */
 
-func skipToNextTable(f *os.File, maxSteps int, debug bool) error {
+func skipToNextTable(f io.ReadSeeker, maxSteps int, debug bool) error {
 	// skip to the next table by advancing in 8-byte steps until non-null bytes are found
 	for i := 0; i < maxSteps; i++ {
 		var sentinel uint64
@@ -40,7 +39,36 @@ func skipToNextTable(f *os.File, maxSteps int, debug bool) error {
 	return nil
 }
 
-func ReadDataMap(f *os.File, gv gameVersion, debug bool) (*DataMap, error) {
+// ReadDataMap reads the game's file tables starting at gv.DataOffset.
+// All tables are keyed by virtual address, just like the pointers stored within them.
+// The ELF headers are used to translate between virtual addresses and file offsets.
+// If gv.MagicOffset is set, the magic offset derived from them has to match it.
+func ReadDataMap(f utils.ReadSeekerAt, gv GameVersion, debug bool) (*DataMap, error) {
+	exe, err := ReadExecutable(f)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse executable: %w", err)
+	}
+
+	return readDataMap(f, gv, exe, debug)
+}
+
+// ReadDataMapWithMagicOffset reads the game's file tables starting at dataOffset like ReadDataMap,
+// but ignores the ELF headers and pretends the entire file is loaded at magicOffset.
+// It's meant for binaries whose headers can't be trusted.
+func ReadDataMapWithMagicOffset(f utils.ReadSeekerAt, dataOffset, magicOffset uint32, debug bool) (*DataMap, error) {
+	return readDataMap(f, GameVersion{DataOffset: dataOffset}, FixedOffsetExecutable(magicOffset), debug)
+}
+
+func readDataMap(f utils.ReadSeekerAt, gv GameVersion, exe *Executable, debug bool) (*DataMap, error) {
+	magicOffset, ok := exe.MagicOffset(gv.DataOffset)
+	if !ok {
+		return nil, fmt.Errorf("data offset 0x%X isn't part of any loadable segment", gv.DataOffset)
+	}
+
+	if gv.MagicOffset != 0 && magicOffset != gv.MagicOffset {
+		return nil, fmt.Errorf("%w: the ELF headers say 0x%X, but this version is known to use 0x%X", ErrMagicOffsetMismatch, magicOffset, gv.MagicOffset)
+	}
+
 	pos, err := f.Seek(int64(gv.DataOffset), io.SeekStart)
 	if err != nil {
 		return nil, err
@@ -52,11 +80,13 @@ func ReadDataMap(f *os.File, gv gameVersion, debug bool) (*DataMap, error) {
 		mergeFileOffsets:  map[uint32]MergeFileEntry{},
 		dataFileOffsets:   map[uint32]DataFileEntry{},
 		filePaths:         map[uint32]string{},
-		magicOffset:       gv.MagicOffset,
+		exe:               exe,
+		magicOffset:       magicOffset,
 	}
 
 	if debug {
 		fmt.Printf("start offset: 0x%X\n", pos)
+		fmt.Printf("magic offset: 0x%X\n", magicOffset)
 	}
 
 	for {
@@ -87,11 +117,19 @@ func ReadDataMap(f *os.File, gv gameVersion, debug bool) (*DataMap, error) {
 		fmt.Printf("pos before file entry table: 0x%X\n", utils.CurrentPos(f))
 	}
 
+	dataMap.typedEntriesStart, err = dataMap.currentAddress(f)
+	if err != nil {
+		return nil, err
+	}
+
 	for {
-		posUint := uint32(utils.CurrentPos(f))
+		addr, err := dataMap.currentAddress(f)
+		if err != nil {
+			return nil, err
+		}
 
 		var entryType FileEntryType
-		err := utils.ReadStructLE(f, &entryType)
+		err = utils.ReadStructLE(f, &entryType)
 		if err != nil {
 			return nil, err
 		}
@@ -104,7 +142,7 @@ func ReadDataMap(f *os.File, gv gameVersion, debug bool) (*DataMap, error) {
 				return nil, err
 			}
 
-			dataMap.binaryFileOffsets[posUint] = entry
+			dataMap.binaryFileOffsets[addr] = entry
 		case EntryTypeMergeFile:
 			var entry MergeFileEntry
 			err := utils.ReadStructLE(f, &entry)
@@ -112,7 +150,7 @@ func ReadDataMap(f *os.File, gv gameVersion, debug bool) (*DataMap, error) {
 				return nil, err
 			}
 
-			dataMap.mergeFileOffsets[posUint] = entry
+			dataMap.mergeFileOffsets[addr] = entry
 		case EntryTypeDataFile:
 			var entry DataFileEntry
 			err := utils.ReadStructLE(f, &entry)
@@ -120,10 +158,11 @@ func ReadDataMap(f *os.File, gv gameVersion, debug bool) (*DataMap, error) {
 				return nil, err
 			}
 
-			dataMap.dataFileOffsets[posUint] = entry
+			dataMap.dataFileOffsets[addr] = entry
 		}
 
 		if entryType == EntryTypeEOF {
+			dataMap.typedEntriesEnd = addr
 			_, _ = f.Seek(-4, io.SeekCurrent)
 			break
 		}
@@ -155,7 +194,12 @@ func ReadDataMap(f *os.File, gv gameVersion, debug bool) (*DataMap, error) {
 			return nil, err
 		}
 
-		dataMap.filePaths[uint32(pathOffset)] = pathEntry
+		pathAddress, ok := dataMap.exe.FileOffsetToVirtual(uint32(pathOffset))
+		if !ok {
+			break // ran past the end of the segment
+		}
+
+		dataMap.filePaths[pathAddress] = pathEntry
 	}
 
 	if debug {
@@ -164,3 +208,15 @@ func ReadDataMap(f *os.File, gv gameVersion, debug bool) (*DataMap, error) {
 
 	return &dataMap, nil
 }
+
+// currentAddress returns the virtual address of the current position in the executable.
+func (d *DataMap) currentAddress(f io.ReadSeeker) (uint32, error) {
+	pos := utils.CurrentPos(f)
+
+	addr, ok := d.exe.FileOffsetToVirtual(uint32(pos))
+	if !ok {
+		return 0, fmt.Errorf("offset 0x%X isn't part of any loadable segment", pos)
+	}
+
+	return addr, nil
+}
@@ -0,0 +1,16 @@
+package sh2
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReadDataMapMagicOffsetMismatch(t *testing.T) {
+	gv := GameVersion{DataOffset: 0x1000, MagicOffset: 0xFF900, Description: "test"}
+
+	_, err := readDataMap(bytes.NewReader(nil), gv, FixedOffsetExecutable(0xFF800), false)
+	if !errors.Is(err, ErrMagicOffsetMismatch) {
+		t.Errorf("err = %v, want %v", err, ErrMagicOffsetMismatch)
+	}
+}
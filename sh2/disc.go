@@ -0,0 +1,64 @@
+package sh2
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrExecutableNotFound = errors.New("can't find the game's executable")
+
+	// matches the names of PS2 executables, like SLUS_202.28 or SLPM_650.51
+	executableNameRegex = regexp.MustCompile(`(?i)^S[CL][A-Z]{2}_\d{3}\.\d{2}$`)
+)
+
+// FindExecutable returns the path of the game's executable within a disc's file system.
+// The BOOT2 line in SYSTEM.CNF is used if possible.
+// Otherwise, the root directory is searched for something that looks like an executable.
+func FindExecutable(fsys fs.FS) (string, error) {
+	cnf, err := fs.ReadFile(fsys, "SYSTEM.CNF")
+	if err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(cnf))
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), "=")
+			if !ok || strings.TrimSpace(key) != "BOOT2" {
+				continue
+			}
+
+			// BOOT2 = cdrom0:\SLUS_202.28;1
+			value = strings.TrimSpace(value)
+			value = strings.TrimPrefix(value, "cdrom0:")
+			value, _, _ = strings.Cut(value, ";")
+			value = strings.TrimLeft(strings.ReplaceAll(value, "\\", "/"), "/")
+
+			if _, err := fs.Stat(fsys, value); err == nil {
+				return value, nil
+			}
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() && executableNameRegex.MatchString(e.Name()) {
+			return e.Name(), nil
+		}
+	}
+
+	return "", ErrExecutableNotFound
+}
+
+// DiscPath turns a path from the path table into one that can be used with fs.FS.
+// File names on the disc are upper case.
+func DiscPath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	return strings.TrimPrefix(path.Clean("/"+strings.ToUpper(p)), "/")
+}
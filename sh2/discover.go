@@ -0,0 +1,180 @@
+package sh2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"sh2unpack/utils"
+)
+
+var (
+	ErrTablesNotFound = errors.New("can't find the file tables")
+)
+
+// Discovery describes where DiscoverTables found the file tables in an executable.
+type Discovery struct {
+	DataOffset  uint32
+	MagicOffset uint32
+
+	FilePathEntries int
+	TypedEntries    int
+
+	// Confidence is a value between 0 and 1 describing how many table entries check out. See DataMap.Confidence.
+	Confidence float64
+}
+
+// scanner holds an executable's contents while searching it for tables.
+type scanner struct {
+	data []byte
+	exe  *Executable
+}
+
+func (s scanner) u32(offset int) uint32 {
+	return binary.LittleEndian.Uint32(s.data[offset:])
+}
+
+// fileOffset translates a virtual address into an offset into s.data.
+func (s scanner) fileOffset(addr uint32) (int, bool) {
+	offset, ok := s.exe.VirtualToFileOffset(addr)
+	if !ok || int(offset) >= len(s.data) {
+		return 0, false
+	}
+
+	return int(offset), true
+}
+
+// isPath checks whether a virtual address points at something that looks like a path string.
+func (s scanner) isPath(addr uint32) bool {
+	offset, ok := s.fileOffset(addr)
+	if !ok || (offset > 0 && s.data[offset-1] != 0) {
+		// path strings never start in the middle of another string
+		return false
+	}
+
+	for i := offset; i < len(s.data) && i < offset+256; i++ {
+		b := s.data[i]
+		if b == 0 {
+			return i > offset
+		} else if b < 0x20 || b >= 0x7F {
+			return false
+		}
+	}
+
+	return false
+}
+
+// isTypedEntry checks whether there's a valid entry of the typed entry table at offset.
+func (s scanner) isTypedEntry(offset int) bool {
+	if offset+16 > len(s.data) {
+		return false
+	}
+
+	switch FileEntryType(s.u32(offset)) {
+	case EntryTypeBinaryFile, EntryTypeMergeFile:
+		return s.isPath(s.u32(offset + 4))
+	case EntryTypeDataFile:
+		_, ok := s.fileOffset(s.u32(offset + 4))
+		return ok
+	}
+
+	return false
+}
+
+// findTypedEntryTable returns the start and end of the longest run of typed entries.
+func (s scanner) findTypedEntryTable() (int, int) {
+	bestStart, bestEnd := 0, 0
+
+	for offset := 0; offset+16 <= len(s.data); {
+		end := offset
+		for s.isTypedEntry(end) {
+			end += 16
+		}
+
+		if end-offset > bestEnd-bestStart {
+			bestStart, bestEnd = offset, end
+		}
+
+		if end > offset+16 {
+			offset = end
+		} else {
+			offset += 4
+		}
+	}
+
+	return bestStart, bestEnd
+}
+
+// findFilePathTable walks backwards from the typed entry table to find the start of the file-to-path table.
+func (s scanner) findFilePathTable(typedStart, typedEnd int) (int, int) {
+	typedStartAddr, _ := s.exe.FileOffsetToVirtual(uint32(typedStart))
+	typedEndAddr, _ := s.exe.FileOffsetToVirtual(uint32(typedEnd))
+
+	// skip the padding between both tables, the same way skipToNextTable does in the other direction
+	end := typedStart
+	for i := 0; i < 32 && end >= 8 && s.u32(end-8) == 0 && s.u32(end-4) == 0; i++ {
+		end -= 8
+	}
+
+	start := end
+	for start >= 8 {
+		fileAddr, pathAddr := s.u32(start-8), s.u32(start-4)
+		if fileAddr < typedStartAddr || fileAddr >= typedEndAddr || !s.isPath(pathAddr) {
+			break
+		}
+		start -= 8
+	}
+
+	return start, (end - start) / 8
+}
+
+// DiscoverTables searches an executable for the file tables.
+// This is meant for versions of the game that aren't in VersionMap, like fan translations.
+// The typed entry table is the most distinctive of the three, so that's what we look for first.
+func DiscoverTables(f utils.ReadSeekerAt) (*Discovery, error) {
+	exe, err := ReadExecutable(f)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse executable: %w", err)
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	s := scanner{data: data, exe: exe}
+
+	typedStart, typedEnd := s.findTypedEntryTable()
+	if typedEnd == typedStart {
+		return nil, ErrTablesNotFound
+	}
+
+	dataOffset, numFilePathEntries := s.findFilePathTable(typedStart, typedEnd)
+	if numFilePathEntries == 0 {
+		return nil, ErrTablesNotFound
+	}
+
+	magicOffset, ok := exe.MagicOffset(uint32(dataOffset))
+	if !ok {
+		return nil, ErrTablesNotFound
+	}
+
+	dataMap, err := ReadDataMap(f, GameVersion{DataOffset: uint32(dataOffset)}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Discovery{
+		DataOffset:      uint32(dataOffset),
+		MagicOffset:     magicOffset,
+		FilePathEntries: numFilePathEntries,
+		TypedEntries:    (typedEnd - typedStart) / 16,
+		Confidence:      dataMap.Confidence(),
+	}, nil
+}
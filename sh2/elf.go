@@ -0,0 +1,99 @@
+package sh2
+
+import (
+	"debug/elf"
+	"errors"
+	"io"
+	"math"
+)
+
+var (
+	ErrNoLoadableSegments  = errors.New("executable has no loadable segments")
+	ErrMagicOffsetMismatch = errors.New("magic offset doesn't match the known one")
+)
+
+// segment is the part of an ELF program header we care about.
+type segment struct {
+	Offset         uint32
+	VirtualAddress uint32
+	Size           uint32
+}
+
+// Executable is a parsed PS2 ELF binary.
+// It's used to translate the virtual addresses stored in the game's tables into file offsets and back.
+type Executable struct {
+	segments []segment
+}
+
+// ReadExecutable parses the ELF headers and loadable segments of a game binary.
+func ReadExecutable(r io.ReaderAt) (*Executable, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	exe := Executable{}
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Filesz == 0 {
+			continue
+		}
+
+		exe.segments = append(exe.segments, segment{
+			Offset:         uint32(prog.Off),
+			VirtualAddress: uint32(prog.Vaddr),
+			Size:           uint32(prog.Filesz),
+		})
+	}
+
+	if len(exe.segments) == 0 {
+		return nil, ErrNoLoadableSegments
+	}
+
+	return &exe, nil
+}
+
+// FixedOffsetExecutable pretends the entire file is loaded at magicOffset.
+// This is what we used to do before parsing the ELF headers,
+// and it's still useful when the headers can't be trusted.
+func FixedOffsetExecutable(magicOffset uint32) *Executable {
+	return &Executable{
+		segments: []segment{{
+			Offset:         0,
+			VirtualAddress: magicOffset,
+			Size:           math.MaxUint32 - magicOffset,
+		}},
+	}
+}
+
+// VirtualToFileOffset translates a virtual address into a file offset.
+func (e *Executable) VirtualToFileOffset(addr uint32) (uint32, bool) {
+	for _, s := range e.segments {
+		if addr >= s.VirtualAddress && addr-s.VirtualAddress < s.Size {
+			return addr - s.VirtualAddress + s.Offset, true
+		}
+	}
+
+	return 0, false
+}
+
+// FileOffsetToVirtual translates a file offset into a virtual address.
+func (e *Executable) FileOffsetToVirtual(offset uint32) (uint32, bool) {
+	for _, s := range e.segments {
+		if offset >= s.Offset && offset-s.Offset < s.Size {
+			return offset - s.Offset + s.VirtualAddress, true
+		}
+	}
+
+	return 0, false
+}
+
+// MagicOffset returns the difference between virtual address and file offset
+// for the segment that contains the given file offset.
+func (e *Executable) MagicOffset(offset uint32) (uint32, bool) {
+	addr, ok := e.FileOffsetToVirtual(offset)
+	if !ok {
+		return 0, false
+	}
+
+	return addr - offset, true
+}
@@ -1,10 +1,14 @@
 package sh2
 
 import (
+	"errors"
 	"fmt"
+
 	"golang.org/x/exp/maps"
-	"golang.org/x/exp/slices"
-	"sh2unpack/utils"
+)
+
+var (
+	ErrUnresolvableEntry = errors.New("unresolvable table entry")
 )
 
 type FileEntryType uint32
@@ -16,6 +20,21 @@ const (
 	EntryTypeDataFile   FileEntryType = 0x50 // files within mergefiles
 )
 
+func (t FileEntryType) String() string {
+	switch t {
+	case EntryTypeEOF:
+		return "EOF"
+	case EntryTypeBinaryFile:
+		return "binary file"
+	case EntryTypeMergeFile:
+		return "mergefile"
+	case EntryTypeDataFile:
+		return "data file"
+	}
+
+	return fmt.Sprintf("unknown (0x%X)", uint32(t))
+}
+
 type FilePathEntry struct {
 	FileOffset uint32
 	PathOffset uint32
@@ -27,7 +46,7 @@ func (e FilePathEntry) String() string {
 
 // MergeFileEntry is used for both binary files and mergefiles.
 // I'm calling it MergeFileEntry for simplicity.
-// Unknown1 and Unknown2 seem to always be zero.
+// Unknown1 and Unknown2 seem to always be zero. Use the stats command to check this across versions.
 type MergeFileEntry struct {
 	PathOffset uint32
 	Unknown1   uint32
@@ -52,9 +71,42 @@ func (e DataFileEntry) String() string {
 	return fmt.Sprintf("{EntryAddress:0x%X ChunkOffset:0x%X ChunkLength:0x%X}", e.EntryAddress, e.ChunkOffset, e.ChunkLength)
 }
 
+// DataFile is a fully resolved data file: where it's supposed to go and where its contents can be found.
+type DataFile struct {
+	Path          string
+	MergeFilePath string
+	ChunkOffset   uint32
+	ChunkLength   uint32
+
+	// Standalone is set for binary files, which aren't part of a mergefile.
+	// MergeFilePath is the path of the file itself then.
+	Standalone bool
+
+	// virtual addresses of the table entries this was resolved from
+	EntryAddress     uint32 // the DataFileEntry itself
+	PathAddress      uint32 // the file's path
+	MergeFileAddress uint32 // DataFileEntry.EntryAddress
+}
+
+// BinaryFile is a standalone file on the disc that's referenced by the tables, like the GX/GY/GZ files.
+type BinaryFile struct {
+	Path string
+
+	// virtual addresses of the table entries this was resolved from
+	EntryAddress uint32 // the MergeFileEntry itself
+	PathAddress  uint32 // the file's path
+}
+
+// DataMap holds the game's file tables. All of its maps are keyed by virtual address.
 type DataMap struct {
 	FileToPathOffsets []FilePathEntry
-	magicOffset       uint32
+
+	exe         *Executable
+	magicOffset uint32
+
+	// virtual addresses of the typed-entry table, the end is exclusive
+	typedEntriesStart uint32
+	typedEntriesEnd   uint32
 
 	binaryFileOffsets map[uint32]MergeFileEntry
 	mergeFileOffsets  map[uint32]MergeFileEntry
@@ -63,22 +115,62 @@ type DataMap struct {
 	filePaths map[uint32]string
 }
 
-// GuessOffset employs Math™ to guess what the file path offset ("magic offset") is.
-// Not always correct. Needs tweaking.
-func (d DataMap) GuessOffset() int {
-	minFTPPathOffset := slices.Min(utils.Map(d.FileToPathOffsets, func(entry FilePathEntry) uint32 {
-		return entry.PathOffset
-	}))
-	minFilePathOffset := slices.Min(maps.Keys(d.filePaths))
+// MagicOffset returns the difference between the virtual addresses and file offsets of the tables.
+// This used to be hard-coded for every version of the game, now it's derived from the ELF headers.
+func (d DataMap) MagicOffset() uint32 {
+	return d.magicOffset
+}
 
-	return utils.IntAbs(int(minFTPPathOffset) - int(minFilePathOffset))
+// FileOffset translates a virtual address into an offset into the executable.
+func (d DataMap) FileOffset(address uint32) (uint32, bool) {
+	return d.exe.VirtualToFileOffset(address)
 }
 
-// GetBinaryFileEntry takes a raw address and returns a MergeFileEntry.
-// Not super helpful at the moment because it's unknown what these files contain.
-func (d DataMap) GetBinaryFileEntry(rawAddress uint32) (MergeFileEntry, bool) {
-	correctedAddress := rawAddress - d.magicOffset
-	entry, ok := d.binaryFileOffsets[correctedAddress]
+// Confidence returns a value between 0 and 1 describing how well the tables check out.
+// It's the share of file-to-path entries that resolve to both an entry and a path,
+// averaged with the share of data files whose mergefile can be found.
+func (d DataMap) Confidence() float64 {
+	if len(d.FileToPathOffsets) == 0 {
+		return 0
+	}
+
+	resolvedEntries := 0
+	for _, ftp := range d.FileToPathOffsets {
+		_, isBinaryFile := d.binaryFileOffsets[ftp.FileOffset]
+		_, isMergeFile := d.mergeFileOffsets[ftp.FileOffset]
+		_, isDataFile := d.dataFileOffsets[ftp.FileOffset]
+		_, hasPath := d.filePaths[ftp.PathOffset]
+
+		if (isBinaryFile || isMergeFile || isDataFile) && hasPath {
+			resolvedEntries++
+		}
+	}
+
+	resolvedDataFiles := 0
+	for _, datEntry := range d.dataFileOffsets {
+		mgfEntry, err := d.GetMergeFileEntryFromDataFileEntry(datEntry)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := d.filePaths[mgfEntry.PathOffset]; ok {
+			resolvedDataFiles++
+		}
+	}
+
+	entryRatio := float64(resolvedEntries) / float64(len(d.FileToPathOffsets))
+	if len(d.dataFileOffsets) == 0 {
+		// no data files means there's nothing to extract
+		return entryRatio / 2
+	}
+
+	dataFileRatio := float64(resolvedDataFiles) / float64(len(d.dataFileOffsets))
+	return (entryRatio + dataFileRatio) / 2
+}
+
+// GetBinaryFileEntry takes a virtual address and returns a MergeFileEntry.
+func (d DataMap) GetBinaryFileEntry(address uint32) (MergeFileEntry, bool) {
+	entry, ok := d.binaryFileOffsets[address]
 	if !ok {
 		return MergeFileEntry{}, false
 	}
@@ -86,10 +178,9 @@ func (d DataMap) GetBinaryFileEntry(rawAddress uint32) (MergeFileEntry, bool) {
 	return entry, true
 }
 
-// GetMergeFileEntry takes a raw address and returns a MergeFileEntry.
-func (d DataMap) GetMergeFileEntry(rawAddress uint32) (MergeFileEntry, bool) {
-	correctedAddress := rawAddress - d.magicOffset
-	entry, ok := d.mergeFileOffsets[correctedAddress]
+// GetMergeFileEntry takes a virtual address and returns a MergeFileEntry.
+func (d DataMap) GetMergeFileEntry(address uint32) (MergeFileEntry, bool) {
+	entry, ok := d.mergeFileOffsets[address]
 	if !ok {
 		return MergeFileEntry{}, false
 	}
@@ -97,10 +188,9 @@ func (d DataMap) GetMergeFileEntry(rawAddress uint32) (MergeFileEntry, bool) {
 	return entry, true
 }
 
-// GetDataFileEntry takes a raw address and returns a DataFileEntry.
-func (d DataMap) GetDataFileEntry(rawAddress uint32) (DataFileEntry, bool) {
-	correctedAddress := rawAddress - d.magicOffset
-	entry, ok := d.dataFileOffsets[correctedAddress]
+// GetDataFileEntry takes a virtual address and returns a DataFileEntry.
+func (d DataMap) GetDataFileEntry(address uint32) (DataFileEntry, bool) {
+	entry, ok := d.dataFileOffsets[address]
 	if !ok {
 		return DataFileEntry{}, false
 	}
@@ -109,25 +199,45 @@ func (d DataMap) GetDataFileEntry(rawAddress uint32) (DataFileEntry, bool) {
 }
 
 // GetMergeFileEntryFromDataFileEntry takes a DataFileEntry and returns a MergeFileEntry.
+// See GetMergeFileAddressFromDataFileEntry for how it's found.
+func (d DataMap) GetMergeFileEntryFromDataFileEntry(datEntry DataFileEntry) (MergeFileEntry, error) {
+	addr, err := d.GetMergeFileAddressFromDataFileEntry(datEntry)
+	if err != nil {
+		return MergeFileEntry{}, err
+	}
+
+	return d.mergeFileOffsets[addr], nil
+}
+
+// GetMergeFileAddressFromDataFileEntry takes a DataFileEntry and returns the virtual address of its MergeFileEntry.
 // This is done by taking the DataFileEntry's EntryAddress value and subtracting 0x10 in a loop until
-// we have an address that matches a MergeFileEntry.
-func (d DataMap) GetMergeFileEntryFromDataFileEntry(datEntry DataFileEntry, minOffset uint32) (MergeFileEntry, bool) {
-	for addr := datEntry.EntryAddress; addr >= minOffset; addr -= 0x10 {
-		correctedAddress := addr - d.magicOffset
-		entry, ok := d.mergeFileOffsets[correctedAddress]
-		if ok {
-			return entry, true
+// we have an address that matches a MergeFileEntry. The walk never leaves the typed-entry table,
+// so tables read from bad offsets fail quickly instead of sending it through the whole address space.
+func (d DataMap) GetMergeFileAddressFromDataFileEntry(datEntry DataFileEntry) (uint32, error) {
+	addr := datEntry.EntryAddress
+	if addr < d.typedEntriesStart || addr >= d.typedEntriesEnd {
+		return 0, fmt.Errorf("%w: 0x%X is outside of the typed-entry table at 0x%X-0x%X",
+			ErrUnresolvableEntry, addr, d.typedEntriesStart, d.typedEntriesEnd)
+	}
+
+	for {
+		if _, ok := d.mergeFileOffsets[addr]; ok {
+			return addr, nil
+		}
+
+		if addr-d.typedEntriesStart < 0x10 {
+			break
 		}
+		addr -= 0x10
 	}
 
-	return MergeFileEntry{}, false
+	return 0, fmt.Errorf("%w: no mergefile entry at or below 0x%X", ErrUnresolvableEntry, datEntry.EntryAddress)
 }
 
-// GetFilePath takes a raw address and returns a file path string.
-// This only works with FilePathEntry.FileOffset or MergeFileEntry.PathOffset addresses.
-func (d DataMap) GetFilePath(rawAddress uint32) (string, bool) {
-	correctedAddress := rawAddress - d.magicOffset
-	path, ok := d.filePaths[correctedAddress]
+// GetFilePath takes a virtual address and returns a file path string.
+// This only works with FilePathEntry.PathOffset or MergeFileEntry.PathOffset addresses.
+func (d DataMap) GetFilePath(address uint32) (string, bool) {
+	path, ok := d.filePaths[address]
 	if !ok {
 		return "", false
 	}
@@ -135,11 +245,86 @@ func (d DataMap) GetFilePath(rawAddress uint32) (string, bool) {
 	return path, true
 }
 
-// debugging functions
-// func (d *DataMap) GetBinaryFileEntries() []MergeFileEntry {
-// 	return maps.Values(d.binaryFileOffsets)
-// }
-//
-// func (d *DataMap) GetMergeFileEntries() []MergeFileEntry {
-// 	return maps.Values(d.mergeFileOffsets)
-// }
+// DataFiles resolves every data file referenced by FileToPathOffsets, in table order.
+func (d DataMap) DataFiles() ([]DataFile, error) {
+	var dataFiles []DataFile
+
+	for _, ftp := range d.FileToPathOffsets {
+		datEntry, ok := d.GetDataFileEntry(ftp.FileOffset)
+		if !ok {
+			continue
+		}
+
+		datPath, ok := d.GetFilePath(ftp.PathOffset)
+		if !ok {
+			return nil, fmt.Errorf("%w: can't find file path for data file at 0x%X", ErrUnresolvableEntry, ftp.PathOffset)
+		}
+
+		mgfEntry, err := d.GetMergeFileEntryFromDataFileEntry(datEntry)
+		if err != nil {
+			return nil, fmt.Errorf("can't find mergefile entry for data file %s (%s): %w", datEntry, datPath, err)
+		}
+
+		mgfPath, ok := d.GetFilePath(mgfEntry.PathOffset)
+		if !ok {
+			return nil, fmt.Errorf("%w: can't find file path for mergefile %s", ErrUnresolvableEntry, mgfEntry)
+		}
+
+		dataFiles = append(dataFiles, DataFile{
+			Path:             datPath,
+			MergeFilePath:    mgfPath,
+			ChunkOffset:      datEntry.ChunkOffset,
+			ChunkLength:      datEntry.ChunkLength,
+			EntryAddress:     ftp.FileOffset,
+			PathAddress:      ftp.PathOffset,
+			MergeFileAddress: datEntry.EntryAddress,
+		})
+	}
+
+	return dataFiles, nil
+}
+
+// BinaryFiles resolves every binary file referenced by FileToPathOffsets, in table order.
+func (d DataMap) BinaryFiles() ([]BinaryFile, error) {
+	var binaryFiles []BinaryFile
+
+	for _, ftp := range d.FileToPathOffsets {
+		binEntry, ok := d.GetBinaryFileEntry(ftp.FileOffset)
+		if !ok {
+			continue
+		}
+
+		binPath, ok := d.GetFilePath(binEntry.PathOffset)
+		if !ok {
+			return nil, fmt.Errorf("%w: can't find file path for binary file %s", ErrUnresolvableEntry, binEntry)
+		}
+
+		binaryFiles = append(binaryFiles, BinaryFile{
+			Path:         binPath,
+			EntryAddress: ftp.FileOffset,
+			PathAddress:  binEntry.PathOffset,
+		})
+	}
+
+	return binaryFiles, nil
+}
+
+// GetBinaryFileEntries returns a copy of every binary file entry, keyed by virtual address.
+func (d DataMap) GetBinaryFileEntries() map[uint32]MergeFileEntry {
+	return maps.Clone(d.binaryFileOffsets)
+}
+
+// GetMergeFileEntries returns a copy of every mergefile entry, keyed by virtual address.
+func (d DataMap) GetMergeFileEntries() map[uint32]MergeFileEntry {
+	return maps.Clone(d.mergeFileOffsets)
+}
+
+// GetFilePaths returns a copy of every path, keyed by virtual address.
+func (d DataMap) GetFilePaths() map[uint32]string {
+	return maps.Clone(d.filePaths)
+}
+
+// GetDataFileEntries returns a copy of every data file entry, keyed by virtual address.
+func (d DataMap) GetDataFileEntries() map[uint32]DataFileEntry {
+	return maps.Clone(d.dataFileOffsets)
+}
@@ -0,0 +1,48 @@
+package sh2
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetMergeFileAddressFromDataFileEntry(t *testing.T) {
+	tests := []struct {
+		name         string
+		start, end   uint32 // typed-entry table
+		mergeFiles   []uint32
+		entryAddress uint32
+		want         uint32
+		wantErr      error
+	}{
+		{"exact", 0x100800, 0x100900, []uint32{0x100800, 0x100850}, 0x100850, 0x100850, nil},
+		{"below", 0x100800, 0x100900, []uint32{0x100800, 0x100850}, 0x1008A0, 0x100850, nil},
+		{"first entry", 0x100800, 0x100900, []uint32{0x100800}, 0x1008F0, 0x100800, nil},
+		{"none below", 0x100800, 0x100900, []uint32{0x1008A0}, 0x100890, 0, ErrUnresolvableEntry},
+		{"before the table", 0x100800, 0x100900, []uint32{0x1007F0}, 0x1007F0, 0, ErrUnresolvableEntry},
+		{"after the table", 0x100800, 0x100900, []uint32{0x100800}, 0x100900, 0, ErrUnresolvableEntry},
+		{"far after the table", 0x100800, 0x100900, []uint32{0x100800}, 0xFFFFFFF0, 0, ErrUnresolvableEntry},
+		{"table at the bottom of the address space", 0x8, 0x100, []uint32{0x10}, 0xC, 0, ErrUnresolvableEntry},
+		{"empty table", 0x100800, 0x100800, nil, 0x100800, 0, ErrUnresolvableEntry},
+	}
+
+	for _, tt := range tests {
+		d := DataMap{
+			mergeFileOffsets:  map[uint32]MergeFileEntry{},
+			typedEntriesStart: tt.start,
+			typedEntriesEnd:   tt.end,
+		}
+		for _, addr := range tt.mergeFiles {
+			d.mergeFileOffsets[addr] = MergeFileEntry{}
+		}
+
+		got, err := d.GetMergeFileAddressFromDataFileEntry(DataFileEntry{EntryAddress: tt.entryAddress})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: got 0x%X, want 0x%X", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package sh2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidVersionEntry = errors.New("invalid version entry")
+	ErrDuplicateVersion    = errors.New("duplicate version entry")
+
+	sha1Regex = regexp.MustCompile(`^[0-9A-F]{40}$`)
+)
+
+// jsonOffset is a uint32 that can be written as either a number or a string like "0x2CCF00" in JSON.
+type jsonOffset uint32
+
+func (o *jsonOffset) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// not a string, maybe a plain number
+		var n uint32
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+
+		*o = jsonOffset(n)
+		return nil
+	}
+
+	parsed, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return err
+	}
+
+	*o = jsonOffset(parsed)
+	return nil
+}
+
+// versionFileEntry is a single entry of a version file. It's a list rather than a map so duplicates can be detected.
+//
+//	[
+//	  {
+//	    "sha1": "3A27DEDDFA81CF30F46F0742C3523230CAC75D9A",
+//	    "isoSha1": "2F4D89736D9240C6F8719E50A8D450A81AD638AE",
+//	    "dataOffset": "0x2CCF00",
+//	    "fileName": "SLUS_202.28",
+//	    "description": "Greatest Hits (NTSC-U)"
+//	  }
+//	]
+type versionFileEntry struct {
+	SHA1        string     `json:"sha1"`
+	ISOSHA1     string     `json:"isoSha1"`
+	DataOffset  jsonOffset `json:"dataOffset"`
+	MagicOffset jsonOffset `json:"magicOffset"`
+	FileName    string     `json:"fileName"`
+	Description string     `json:"description"`
+}
+
+// sameVersion reports whether a version file entry describes an existing version.
+// The magic offset is only a cross-check, so an entry without one matches any.
+func sameVersion(existing, gv GameVersion) bool {
+	if gv.MagicOffset == 0 {
+		gv.MagicOffset = existing.MagicOffset
+	}
+
+	return existing == gv
+}
+
+// LoadVersionFile reads additional version definitions from a JSON file and merges them into VersionMap.
+// Entries that are identical to existing ones are skipped, conflicting entries are an error.
+// Returns the number of newly added versions.
+func LoadVersionFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var entries []versionFileEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	newVersions := map[string]GameVersion{}
+	for i, e := range entries {
+		hash := strings.ToUpper(e.SHA1)
+		isoHash := strings.ToUpper(e.ISOSHA1)
+
+		switch {
+		case !sha1Regex.MatchString(hash):
+			return 0, fmt.Errorf("%s: entry %d: %w: sha1 must be 40 hex digits", path, i, ErrInvalidVersionEntry)
+		case isoHash != "" && !sha1Regex.MatchString(isoHash):
+			return 0, fmt.Errorf("%s: entry %d: %w: isoSha1 must be 40 hex digits", path, i, ErrInvalidVersionEntry)
+		case e.DataOffset == 0:
+			return 0, fmt.Errorf("%s: entry %d: %w: dataOffset is missing", path, i, ErrInvalidVersionEntry)
+		}
+
+		gv := GameVersion{
+			DataOffset:  uint32(e.DataOffset),
+			MagicOffset: uint32(e.MagicOffset),
+			FileName:    e.FileName,
+			Description: e.Description,
+			ISOHash:     isoHash,
+		}
+
+		if _, ok := newVersions[hash]; ok {
+			return 0, fmt.Errorf("%s: entry %d: %w: %s appears more than once", path, i, ErrDuplicateVersion, hash)
+		}
+
+		if existing, ok := VersionMap[hash]; ok {
+			if !sameVersion(existing, gv) {
+				return 0, fmt.Errorf("%s: entry %d: %w: %s conflicts with %s", path, i, ErrDuplicateVersion, hash, existing.Description)
+			}
+
+			continue
+		}
+
+		if isoHash != "" {
+			if existing, ok := VersionFromISOHash(isoHash); ok {
+				return 0, fmt.Errorf("%s: entry %d: %w: ISO hash %s is already used by %s", path, i, ErrDuplicateVersion, isoHash, existing.Description)
+			}
+
+			for _, other := range newVersions {
+				if other.ISOHash == isoHash {
+					return 0, fmt.Errorf("%s: entry %d: %w: ISO hash %s appears more than once", path, i, ErrDuplicateVersion, isoHash)
+				}
+			}
+		}
+
+		newVersions[hash] = gv
+	}
+
+	// only merge once the whole file checks out
+	for hash, gv := range newVersions {
+		VersionMap[hash] = gv
+	}
+
+	return len(newVersions), nil
+}
@@ -0,0 +1,87 @@
+package sh2
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadVersionFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		wantAdded int
+		wantErr   error
+	}{
+		{
+			// the example from the README and the LoadVersionFile docs
+			name: "same as a built-in version",
+			json: `[{"sha1": "3A27DEDDFA81CF30F46F0742C3523230CAC75D9A", "isoSha1": "2F4D89736D9240C6F8719E50A8D450A81AD638AE",
+				"dataOffset": "0x2CCF00", "fileName": "SLUS_202.28", "description": "Greatest Hits (NTSC-U)"}]`,
+		},
+		{
+			name: "same as a built-in version with its magic offset",
+			json: `[{"sha1": "3A27DEDDFA81CF30F46F0742C3523230CAC75D9A", "isoSha1": "2F4D89736D9240C6F8719E50A8D450A81AD638AE",
+				"dataOffset": "0x2CCF00", "magicOffset": "0xFF800", "fileName": "SLUS_202.28", "description": "Greatest Hits (NTSC-U)"}]`,
+		},
+		{
+			name: "different magic offset",
+			json: `[{"sha1": "3A27DEDDFA81CF30F46F0742C3523230CAC75D9A", "isoSha1": "2F4D89736D9240C6F8719E50A8D450A81AD638AE",
+				"dataOffset": "0x2CCF00", "magicOffset": "0xFF900", "fileName": "SLUS_202.28", "description": "Greatest Hits (NTSC-U)"}]`,
+			wantErr: ErrDuplicateVersion,
+		},
+		{
+			name: "different data offset",
+			json: `[{"sha1": "3A27DEDDFA81CF30F46F0742C3523230CAC75D9A", "isoSha1": "2F4D89736D9240C6F8719E50A8D450A81AD638AE",
+				"dataOffset": "0x2CCF80", "fileName": "SLUS_202.28", "description": "Greatest Hits (NTSC-U)"}]`,
+			wantErr: ErrDuplicateVersion,
+		},
+		{
+			name:      "new version",
+			json:      `[{"sha1": "0000000000000000000000000000000000000001", "dataOffset": 4096, "fileName": "SLUS_999.99"}]`,
+			wantAdded: 1,
+		},
+		{
+			name:    "new version twice",
+			json:    `[{"sha1": "0000000000000000000000000000000000000002", "dataOffset": 4096}, {"sha1": "0000000000000000000000000000000000000002", "dataOffset": 4096}]`,
+			wantErr: ErrDuplicateVersion,
+		},
+		{
+			name:    "no data offset",
+			json:    `[{"sha1": "0000000000000000000000000000000000000003"}]`,
+			wantErr: ErrInvalidVersionEntry,
+		},
+		{
+			name:    "bad hash",
+			json:    `[{"sha1": "1234", "dataOffset": 4096}]`,
+			wantErr: ErrInvalidVersionEntry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "versions.json")
+			err := os.WriteFile(p, []byte(tt.json), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := len(VersionMap)
+			t.Cleanup(func() {
+				for _, hash := range []string{"0000000000000000000000000000000000000001", "0000000000000000000000000000000000000002"} {
+					delete(VersionMap, hash)
+				}
+			})
+
+			added, err := LoadVersionFile(p)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if added != tt.wantAdded || len(VersionMap) != before+tt.wantAdded {
+				t.Errorf("added %d versions and VersionMap grew by %d, want %d", added, len(VersionMap)-before, tt.wantAdded)
+			}
+		})
+	}
+}
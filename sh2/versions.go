@@ -1,27 +1,35 @@
 package sh2
 
-type gameVersion struct {
-	DataOffset  uint32
+type GameVersion struct {
+	DataOffset uint32
+
+	// MagicOffset is the difference between virtual addresses and file offsets of the tables this version is known to use.
+	// The magic offset is always derived from the ELF headers, this is only a cross-check:
+	// if it's set and the headers say otherwise, the version can't be loaded.
 	MagicOffset uint32
+
 	FileName    string
 	Description string
+	ISOHash     string // SHA1 hash of the redump disc image
 }
 
 var (
 	// map of game binary SHA1 hash -> game version
-	VersionMap = map[string]gameVersion{
+	VersionMap = map[string]GameVersion{
 		// NTSC-U
 		"ECFD22C67F7712480F52D0674B70964D2A82E648": {
 			DataOffset:  0x2BB180,
 			MagicOffset: 0xFF900,
 			FileName:    "SLUS_202.28",
 			Description: "Silent Hill 2 (NTSC-U)",
+			ISOHash:     "F7FCB40D8C79A6AC622299069A4DC2900C74B200",
 		},
 		"3A27DEDDFA81CF30F46F0742C3523230CAC75D9A": {
 			DataOffset:  0x2CCF00,
 			MagicOffset: 0xFF800,
 			FileName:    "SLUS_202.28",
 			Description: "Greatest Hits (NTSC-U)",
+			ISOHash:     "2F4D89736D9240C6F8719E50A8D450A81AD638AE",
 		},
 
 		// NTSC-J
@@ -30,18 +38,21 @@ var (
 			MagicOffset: 0xFF900,
 			FileName:    "SLPM_650.51",
 			Description: "Silent Hill 2 (NTSC-J, Japan)",
+			ISOHash:     "6A9C80C3D965EE0E50A4FC131AE0D3D9F2384552",
 		},
 		"279A1B4DBFD43FF7A5920A52D51B153C638D1D6B": {
 			DataOffset:  0x2CD080,
 			MagicOffset: 0xFF800,
 			FileName:    "SLKA_250.01",
 			Description: "Silent Hill 2 (NTSC-J, South Korea)",
+			ISOHash:     "5A215C62899F4DA374B9F6E0E56CA6CA6D0A06CB",
 		},
 		"EFA89AA35054A9A547F22673AB601CFB333587DE": {
 			DataOffset:  0x2CCB80,
 			MagicOffset: 0xFF800,
 			FileName:    "SLPM_650.98",
 			Description: "Saigo no Uta (NTSC-J)",
+			ISOHash:     "9BDF3E49F22366B0C27EC9F1EE31721A5106B1B4",
 		},
 
 		// PAL
@@ -50,12 +61,14 @@ var (
 			MagicOffset: 0xFF800,
 			FileName:    "SLES_503.82",
 			Description: "Special 2 Disc Set (PAL)",
+			ISOHash:     "924409DE4DC4CABD4A978FAE7DE94159E57A1C8D",
 		},
 		"2C5A7AFBA3A5B4507CCB828811C8ADD9E5D0E961": {
 			DataOffset:  0x2CD980,
 			MagicOffset: 0xFF800,
 			FileName:    "SLES_511.56",
 			Description: "Director's Cut (PAL)",
+			ISOHash:     "3A2B03AEF487AE88BA5C51B064AAF8295398F684",
 		},
 
 		// Demos/Prototypes
@@ -64,18 +77,32 @@ var (
 			MagicOffset: 0xFFF80,
 			FileName:    "SLPM_123.45",
 			Description: "E3 2001 (NTSC-U)",
+			ISOHash:     "02F2E34E018596A31C0A5CAB1B6BA981ABC2F008",
 		},
 		"888EFF71606FF4C1C610E30111B3CA5DA647EDCC": {
 			DataOffset:  0x29CD00,
 			MagicOffset: 0xFF900,
 			FileName:    "SLUS_202.28",
 			Description: "Jul 13, 2001 prototype (NTSC-U)",
+			ISOHash:     "BBEBD65FCD3E792C3A57DBADF3EE1DEB2846172E",
 		},
 		"B9CB2E895FC83CD4452DC9A818BF3CA26394ADBE": {
 			DataOffset:  0x2B3120,
 			MagicOffset: 0xFF900,
 			FileName:    "SLPM_610.09",
 			Description: "Red Ribbon Demo (NTSC-J)",
+			ISOHash:     "469DDB3E50EEFBF2C5BBC39E1FDF6FC039AD502B",
 		},
 	}
 )
+
+// VersionFromISOHash looks up a game version by the SHA1 hash of its redump disc image.
+func VersionFromISOHash(hash string) (GameVersion, bool) {
+	for _, gv := range VersionMap {
+		if gv.ISOHash == hash {
+			return gv, true
+		}
+	}
+
+	return GameVersion{}, false
+}
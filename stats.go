@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"sh2unpack/sh2"
+	"sh2unpack/utils"
+)
+
+// unknownSample is a binary file or mergefile entry along with what's known about it,
+// so its Unknown1 and Unknown2 fields can be correlated with the rest.
+type unknownSample struct {
+	kind    string // "binary file" or "mergefile"
+	path    string
+	address uint32
+	entry   sh2.MergeFileEntry
+}
+
+func (s unknownSample) nonZero() bool {
+	return s.entry.Unknown1 != 0 || s.entry.Unknown2 != 0
+}
+
+// extension returns the sample's lowercase file extension, or "(none)".
+func (s unknownSample) extension() string {
+	ext := strings.ToLower(path.Ext(s.path))
+	if ext == "" {
+		return "(none)"
+	}
+	return ext
+}
+
+// collectUnknownSamples gathers every binary file and mergefile entry from the data map, sorted by address.
+func collectUnknownSamples(dataMap *sh2.DataMap) []unknownSample {
+	var samples []unknownSample
+
+	add := func(kind string, entries map[uint32]sh2.MergeFileEntry) {
+		addresses := maps.Keys(entries)
+		slices.Sort(addresses)
+
+		for _, addr := range addresses {
+			entry := entries[addr]
+			p, ok := dataMap.GetFilePath(entry.PathOffset)
+			if !ok {
+				p = fmt.Sprintf("<0x%X>", entry.PathOffset)
+			}
+
+			samples = append(samples, unknownSample{kind: kind, path: p, address: addr, entry: entry})
+		}
+	}
+
+	add("binary file", dataMap.GetBinaryFileEntries())
+	add("mergefile", dataMap.GetMergeFileEntries())
+
+	return samples
+}
+
+// printDistribution prints how often each value occurs, in ascending order of value.
+func printDistribution(name string, values []uint32) {
+	dist := utils.NumberDistribution(values)
+	keys := maps.Keys(dist)
+	slices.Sort(keys)
+
+	fmt.Printf("  %s:\n", name)
+	for _, k := range keys {
+		fmt.Printf("    0x%08X: %d\n", k, dist[k])
+	}
+}
+
+// printCorrelation prints how many samples in each group have non-zero unknown fields.
+func printCorrelation(name string, samples []unknownSample, group func(unknownSample) string) {
+	total := map[string]int{}
+	nonZero := map[string]int{}
+	for _, s := range samples {
+		g := group(s)
+		total[g]++
+		if s.nonZero() {
+			nonZero[g]++
+		}
+	}
+
+	groups := maps.Keys(total)
+	slices.Sort(groups)
+
+	fmt.Printf("  Non-zero by %s:\n", name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, g := range groups {
+		_, _ = fmt.Fprintf(w, "    %s\t%d of %d\n", g, nonZero[g], total[g])
+	}
+	_ = w.Flush()
+}
+
+func printUnknownStats(samples []unknownSample, listNonZero bool) {
+	unknown1 := utils.Map(samples, func(s unknownSample) uint32 { return s.entry.Unknown1 })
+	unknown2 := utils.Map(samples, func(s unknownSample) uint32 { return s.entry.Unknown2 })
+
+	printDistribution("Unknown1", unknown1)
+	printDistribution("Unknown2", unknown2)
+	printCorrelation("entry type", samples, func(s unknownSample) string { return s.kind })
+	printCorrelation("extension", samples, unknownSample.extension)
+
+	nonZero := utils.Filter(samples, unknownSample.nonZero)
+	if !listNonZero || len(nonZero) == 0 {
+		return
+	}
+
+	fmt.Println("  Non-zero entries:")
+	for _, s := range nonZero {
+		fmt.Printf("    %s %s at 0x%X: %s\n", s.kind, s.path, s.address, s.entry)
+	}
+}
+
+func (opts *StatsOptions) Execute(args []string) error {
+	var allSamples []unknownSample
+
+	for _, p := range opts.Pos.Inputs {
+		inputOpts := inputOptionsForPath(string(p), opts.SkipISOHash, opts.VersionFile)
+
+		input, err := inputOpts.open(opts.Debug, false)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+
+		input.printVersion()
+
+		gameVersion := input.Version
+		samples := collectUnknownSamples(input.DataMap)
+		_ = input.Close()
+		allSamples = append(allSamples, samples...)
+
+		fmt.Printf("%s (%s, %s): %d entries\n", p, gameVersion.FileName, gameVersion.Description, len(samples))
+		printUnknownStats(samples, true)
+		fmt.Println()
+	}
+
+	if len(opts.Pos.Inputs) > 1 {
+		fmt.Printf("All %d inputs: %d entries\n", len(opts.Pos.Inputs), len(allSamples))
+		printUnknownStats(allSamples, false)
+	}
+
+	return nil
+}
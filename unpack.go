@@ -1,131 +1,436 @@
 package main
 
 import (
+	"crypto/sha1"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
-	"strings"
+	"runtime"
+	"sync"
 
+	"sh2unpack/audio"
+	"sh2unpack/filetype"
 	"sh2unpack/sh2"
 	"sh2unpack/utils"
 )
 
-func (opts *UnpackOptions) Execute(args []string) error {
-	inFilePath := string(opts.InFile)
-	outDirPath := string(opts.Pos.OutDir)
+// extractJob is a single data file to be extracted by one of the workers.
+type extractJob struct {
+	dataFile        sh2.DataFile
+	mergeFile       io.ReaderAt
+	destinationPath string // where the file is extracted to, placeFiles updates it if the file is moved
 
-	fmt.Printf("Input: %s\n", inFilePath)
-	fmt.Printf("Output Folder: %s\n", outDirPath)
+	// filled in by the worker
+	fileType   filetype.Type
+	sha1       string
+	err        error
+	convertErr error // the file was extracted, but converting it failed
+}
 
-	inFile, err := os.Open(inFilePath)
-	if err != nil {
-		return fmt.Errorf("Can't open file: %v", err)
-	}
+// needsFileTypes reports whether any of the options depend on the types of the extracted files.
+func (opts *UnpackOptions) needsFileTypes() bool {
+	return opts.GroupByType || opts.FixExtensions || opts.DemuxPSS || opts.DecodeVAG || opts.DecodeBGM || opts.Manifest != ""
+}
 
-	shaString, err := utils.HashFileSHA1(inFile)
-	if err != nil {
-		return fmt.Errorf("Can't hash input file: %v", err)
+// extract copies a data file's chunk out of its mergefile, hashing it and detecting its type along the way if needed.
+// Mergefiles are only ever read with positional reads, so any number of these can run at the same time.
+func (opts *UnpackOptions) extract(job *extractJob) {
+	mgfBase := filepath.Base(job.dataFile.MergeFilePath)
+	length := int64(job.dataFile.ChunkLength)
+
+	h := sha1.New()
+	var sniffer filetype.Sniffer
+
+	var writers []io.Writer
+	if opts.Manifest != "" {
+		writers = append(writers, h)
+	}
+	if opts.needsFileTypes() {
+		writers = append(writers, &sniffer)
 	}
 
-	gameVersion, ok := sh2.VersionMap[shaString]
-	if !ok {
-		return fmt.Errorf("Not a supported file or gameVersion of the game: %s", inFilePath)
+	if !opts.DryRun {
+		destinationDir := filepath.Dir(job.destinationPath)
+		err := os.MkdirAll(destinationDir, 0700)
+		if err != nil {
+			job.err = fmt.Errorf("Can't create destination dir %s: %v", destinationDir, err)
+			return
+		}
+
+		f, err := os.Create(job.destinationPath)
+		if err != nil {
+			job.err = fmt.Errorf("Can't create destination file %s: %v", job.destinationPath, err)
+			return
+		}
+		defer f.Close()
+
+		writers = append(writers, f)
+	} else if opts.Manifest == "" {
+		// nothing to write and nothing to hash, detecting the type only takes the first few bytes
+		length = min(length, filetype.SniffLength)
 	}
 
-	fmt.Printf("Version detected: %s, %s\n", gameVersion.FileName, gameVersion.Description)
+	if len(writers) == 0 {
+		return
+	}
 
-	dataMap, err := sh2.ReadDataMap(inFile, gameVersion, opts.Debug)
+	err := utils.CopyPartOfFileToFile(io.MultiWriter(writers...), job.mergeFile, int64(job.dataFile.ChunkOffset), length)
 	if err != nil {
-		return fmt.Errorf("Couldn't read data map: %v", err)
+		job.err = fmt.Errorf("Can't copy chunk from %s to %s: %v", mgfBase, job.destinationPath, err)
+		return
+	}
+
+	if opts.Manifest != "" {
+		job.sha1 = fmt.Sprintf("%X", h.Sum(nil))
 	}
+	job.fileType = sniffer.Type()
+}
 
-	// explicitly close the input file here, it's no longer needed
-	_ = inFile.Close()
+// convertedPaths returns the files convert writes for a job, nil if it doesn't convert it.
+func (opts *UnpackOptions) convertedPaths(job *extractJob) []string {
+	basePath := trimExt(job.destinationPath)
 
-	if opts.Debug {
-		guessedOffset := dataMap.GuessOffset()
-		fmt.Printf("guessed offset: 0x%X\n", guessedOffset)
-		fmt.Printf("actual offset:  0x%X\n", gameVersion.MagicOffset)
+	switch {
+	case opts.DemuxPSS && job.fileType == filetype.PSS:
+		return []string{basePath + ".m2v", basePath + ".wav"}
+	case opts.DecodeVAG && job.fileType == filetype.VAG, opts.DecodeBGM && job.fileType == filetype.ADS:
+		return []string{basePath + ".wav"}
 	}
 
-	mergeFileMap := map[string]*os.File{}
-	defer func() {
-		for _, f := range mergeFileMap {
-			_ = f.Close()
+	return nil
+}
+
+// planConversions picks the jobs to convert, one per group, as only the last job of a group wrote the file.
+// Conversions that would overwrite an existing file, or the output of another conversion, are skipped.
+func (opts *UnpackOptions) planConversions(groups [][]*extractJob) [][]*extractJob {
+	var planned [][]*extractJob
+	taken := map[string]string{}
+
+	for _, group := range groups {
+		job := group[len(group)-1]
+		if job.err != nil {
+			continue
 		}
-	}()
 
-	if opts.DryRun {
-		fmt.Println("Doing a dry run.")
-	}
+		paths := opts.convertedPaths(job)
+		if len(paths) == 0 {
+			continue
+		}
 
-	numExtractedFiles := 0
+		conflict := ""
+		for _, p := range paths {
+			if other, ok := taken[p]; ok {
+				conflict = fmt.Sprintf("the one converted from %s", other)
+			} else if _, err := os.Lstat(p); err == nil {
+				conflict = p
+			}
 
-	// iterate over the data files in the FTP list
-	for _, ftp := range dataMap.FileToPathOffsets {
-		datEntry, ok := dataMap.GetDataFileEntry(ftp.FileOffset)
-		if !ok {
+			if conflict != "" {
+				break
+			}
+		}
+
+		if conflict != "" {
+			fmt.Printf("Not converting %s, it would overwrite %s\n", job.dataFile.Path, conflict)
 			continue
 		}
 
-		datPath, ok := dataMap.GetFilePath(ftp.PathOffset)
-		if !ok {
-			return fmt.Errorf("Can't find file path for data file at offset 0x%X", ftp.PathOffset)
+		for _, p := range paths {
+			taken[p] = job.dataFile.Path
 		}
+		planned = append(planned, []*extractJob{job})
+	}
 
-		mgfEntry, ok := dataMap.GetMergeFileEntryFromDataFileEntry(datEntry, gameVersion.DataOffset)
-		if !ok {
-			return fmt.Errorf("Can't find mergefile entry for data file %[2]s (%[3]s)", ftp.PathOffset, datEntry, datPath)
+	return planned
+}
+
+// convert runs the requested conversions on an extracted file, reading straight from the mergefile.
+// Converted files are put next to the extracted one, at the paths convertedPaths returns.
+func (opts *UnpackOptions) convert(job *extractJob) error {
+	chunk := io.NewSectionReader(job.mergeFile, int64(job.dataFile.ChunkOffset), int64(job.dataFile.ChunkLength))
+	basePath := trimExt(job.destinationPath)
+
+	switch {
+	case opts.DemuxPSS && job.fileType == filetype.PSS:
+		_, err := demuxPSS(chunk, basePath)
+		if err != nil {
+			return fmt.Errorf("Can't demux %s: %v", job.dataFile.Path, err)
+		}
+	case opts.DecodeVAG && job.fileType == filetype.VAG:
+		data, err := io.ReadAll(chunk)
+		if err != nil {
+			return err
 		}
 
-		mgfPath, ok := dataMap.GetFilePath(mgfEntry.PathOffset)
-		if !ok {
-			return fmt.Errorf("Can't find file path for mergefile %[2]s", ftp.PathOffset, mgfEntry)
+		wav, err := decodeVAG(data, 0)
+		if err != nil {
+			return fmt.Errorf("Can't decode %s: %v", job.dataFile.Path, err)
+		}
+
+		return writeWAVFile(basePath+".wav", wav)
+	case opts.DecodeBGM && job.fileType == filetype.ADS:
+		data, err := io.ReadAll(chunk)
+		if err != nil {
+			return err
 		}
 
-		mergeFile, ok := mergeFileMap[mgfPath]
+		wav, _, err := decodeBGM(data, audio.StreamHeader{})
+		if err != nil {
+			return fmt.Errorf("Can't decode %s: %v", job.dataFile.Path, err)
+		}
+
+		return writeWAVFile(basePath+".wav", wav)
+	}
+
+	return nil
+}
+
+// groupByDestination groups jobs that write to the same file, in order of their first job.
+// Jobs keep their table order within a group.
+func groupByDestination(jobs []*extractJob) [][]*extractJob {
+	var groups [][]*extractJob
+	groupIndex := map[string]int{}
+
+	for _, job := range jobs {
+		i, ok := groupIndex[job.destinationPath]
 		if !ok {
-			actualMGFPath := filepath.Join(filepath.Dir(inFilePath), strings.ToUpper(mgfPath))
+			i = len(groups)
+			groupIndex[job.destinationPath] = i
+			groups = append(groups, nil)
+		}
 
-			f, err := os.Open(actualMGFPath)
-			if err != nil {
-				return fmt.Errorf("Can't open mergefile: %v", err)
+		groups[i] = append(groups[i], job)
+	}
+
+	return groups
+}
+
+// runGrouped calls fn for every job on numWorkers goroutines.
+// The jobs of a group are run one after another by the same goroutine, so when several entries share a destination,
+// the last one in table order wins, just like it did when extraction was sequential.
+func runGrouped(numWorkers int, groups [][]*extractJob, fn func(*extractJob)) {
+	groupChan := make(chan []*extractJob)
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groupChan {
+				for _, job := range group {
+					fn(job)
+				}
+			}
+		}()
+	}
+
+	for _, group := range groups {
+		groupChan <- group
+	}
+	close(groupChan)
+	wg.Wait()
+}
+
+// destination returns where a file with the given type goes relative to the output directory.
+func (opts *UnpackOptions) destination(p string, fileType filetype.Type, fixExtension bool) string {
+	if fixExtension {
+		p = filetype.FixExtension(p, fileType)
+	}
+
+	if opts.GroupByType {
+		p = path.Join(fileType.Name, p)
+	}
+
+	return p
+}
+
+// placeFiles moves extracted files to where their types say they go.
+// Files are handled in table order, so the outcome doesn't depend on the order the workers finished in.
+// A file is never moved onto another one, if its fixed extension would do that, it keeps the original one.
+func (opts *UnpackOptions) placeFiles(outDirPath string, groups [][]*extractJob) []error {
+	if !opts.GroupByType && !opts.FixExtensions {
+		return nil
+	}
+
+	// every extracted file's path is taken until the file is moved away
+	taken := map[string]string{}
+	for _, group := range groups {
+		taken[group[0].destinationPath] = group[len(group)-1].dataFile.Path
+	}
+
+	var errs []error
+	for _, group := range groups {
+		// the last job in a group is the one that wrote the file
+		job := group[len(group)-1]
+		if job.err != nil {
+			continue
+		}
+
+		from := job.destinationPath
+		to := filepath.Join(outDirPath, filepath.FromSlash(opts.destination(job.dataFile.Path, job.fileType, false)))
+
+		if opts.FixExtensions {
+			fixed := filepath.Join(outDirPath, filepath.FromSlash(opts.destination(job.dataFile.Path, job.fileType, true)))
+			if other, ok := taken[fixed]; ok && other != job.dataFile.Path {
+				fmt.Printf("Not fixing the extension of %s, it would overwrite %s\n", job.dataFile.Path, other)
+			} else {
+				to = fixed
 			}
-			mergeFileMap[mgfPath] = f
-			mergeFile = f
 		}
 
-		destinationPath := filepath.Join(outDirPath, datPath)
-		destinationDir := filepath.Dir(destinationPath)
-		mgfBase := filepath.Base(mgfPath)
+		if to == from {
+			continue
+		}
+
+		if other, ok := taken[to]; ok && other != job.dataFile.Path {
+			fmt.Printf("Not moving %s to %s, it would overwrite %s\n", job.dataFile.Path, to, other)
+			continue
+		}
 
 		if !opts.DryRun {
-			err = os.MkdirAll(destinationDir, 0700)
-			if err != nil {
-				return fmt.Errorf("Can't create destination dir %s: %v", destinationDir, err)
+			err := os.MkdirAll(filepath.Dir(to), 0700)
+			if err == nil {
+				err = os.Rename(from, to)
 			}
-
-			f, err := os.Create(destinationPath)
 			if err != nil {
-				return fmt.Errorf("Can't create destination file %s: %v", destinationPath, err)
+				errs = append(errs, fmt.Errorf("Can't move %s to %s: %v", from, to, err))
+				continue
 			}
 
-			err = utils.CopyPartOfFileToFile(f, mergeFile, int64(datEntry.ChunkOffset), int64(datEntry.ChunkLength))
-			if err != nil {
-				return fmt.Errorf("Can't copy chunk from %s to %s: %v", mgfBase, destinationPath, err)
-			}
-			_ = f.Close()
+			removeEmptyDirs(filepath.Dir(from), outDirPath)
+		}
 
-			if opts.Debug {
-				fmt.Printf("Extracted %d bytes from %s to %s\n", datEntry.ChunkLength, mgfBase, destinationPath)
-			}
+		delete(taken, from)
+		taken[to] = job.dataFile.Path
+		for _, j := range group {
+			j.destinationPath = to
+		}
+	}
+
+	return errs
+}
+
+// removeEmptyDirs removes dir and its parents up to, but not including, stopAt, as long as they're empty.
+func removeEmptyDirs(dir, stopAt string) {
+	stopAt = filepath.Clean(stopAt)
+	for dir = filepath.Clean(dir); dir != stopAt && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+func (opts *UnpackOptions) Execute(args []string) error {
+	outDirPath := string(opts.Pos.OutDir)
+
+	filter, err := opts.FilterOptions.compile()
+	if err != nil {
+		return err
+	}
+
+	input, err := opts.InputOptions.open(opts.Debug, opts.BinaryFiles)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	fmt.Printf("Input: %s\n", input.BinaryPath)
+	fmt.Printf("Output Folder: %s\n", outDirPath)
+	input.printVersion()
+
+	gameVersion, dataMap := input.Version, input.DataMap
+
+	if opts.DryRun {
+		fmt.Println("Doing a dry run.")
+	}
+
+	dataFiles, err := filter.collect(input)
+	if err != nil {
+		return err
+	}
+
+	// open all mergefiles up front so the workers don't have to
+	var jobs []*extractJob
+	for _, dataFile := range dataFiles {
+		mergeFile, err := input.OpenFile(dataFile.MergeFilePath)
+		if err != nil {
+			return fmt.Errorf("Can't open mergefile: %v", err)
+		}
+
+		jobs = append(jobs, &extractJob{
+			dataFile:        dataFile,
+			mergeFile:       mergeFile,
+			destinationPath: filepath.Join(outDirPath, filepath.FromSlash(dataFile.Path)),
+			fileType:        filetype.Unknown,
+		})
+	}
+
+	numWorkers := opts.Jobs
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+
+	groups := groupByDestination(jobs)
+	runGrouped(numWorkers, groups, opts.extract)
+
+	moveErrs := opts.placeFiles(outDirPath, groups)
+
+	// conversions go next to the files, so they have to wait until the files are where they belong
+	if !opts.DryRun {
+		runGrouped(numWorkers, opts.planConversions(groups), func(job *extractJob) {
+			job.convertErr = opts.convert(job)
+		})
+	}
+
+	// everything below happens in table order, regardless of the order the workers finished in
+	m := newManifest(gameVersion, dataMap)
+	numExtractedFiles := 0
+	var errs, convertErrs []error
+
+	for _, job := range jobs {
+		if job.err != nil {
+			errs = append(errs, job.err)
+			continue
+		}
+
+		if job.convertErr != nil {
+			convertErrs = append(convertErrs, job.convertErr)
+		}
+
+		if opts.Debug && !opts.DryRun {
+			fmt.Printf("Extracted %d bytes from %s to %s\n", job.dataFile.ChunkLength, filepath.Base(job.dataFile.MergeFilePath), job.destinationPath)
 		}
 
 		numExtractedFiles++
 
+		entry := newManifestEntry(job.dataFile, job.fileType, job.sha1)
+		entry.Destination = job.destinationPath
+		m.Files = append(m.Files, entry)
 	}
 
 	fmt.Printf("Extracted %d files.\n", numExtractedFiles)
 
-	return nil
+	if len(errs) > 0 {
+		return fmt.Errorf("%d files couldn't be extracted:\n%w", len(errs), errors.Join(errs...))
+	}
+
+	if opts.Manifest != "" {
+		err := opts.ManifestOptions.write(m)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the files are there, so these don't stop the manifest from being written
+	var laterErrs []error
+	if len(moveErrs) > 0 {
+		laterErrs = append(laterErrs, fmt.Errorf("%d files couldn't be moved to where their type says they go:\n%w", len(moveErrs), errors.Join(moveErrs...)))
+	}
+	if len(convertErrs) > 0 {
+		laterErrs = append(laterErrs, fmt.Errorf("%d files couldn't be converted:\n%w", len(convertErrs), errors.Join(convertErrs...)))
+	}
+
+	return errors.Join(laterErrs...)
 }
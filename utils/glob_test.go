@@ -0,0 +1,45 @@
+package utils
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"data/chr/*.bin", "data/chr/maria.bin", true},
+		{"data/chr/*.bin", "data/chr/npc/maria.bin", false},
+		{"data/*", "data/chr/maria.bin", false},
+		{"data/**", "data/chr/maria.bin", true},
+		{"**/*.bin", "data/chr/maria.bin", true},
+		{"**.bin", "data/chr/maria.bin", true},
+		{"data/chr/maria.bin", "data/chr/maria.bin", true},
+		{"data/chr/maria.bin", "xdata/chr/maria.bin", false},
+		{"data/chr/maria.bin", "data/chr/maria.binx", false},
+		{"data/chr/?aria.bin", "data/chr/maria.bin", true},
+		{"data/chr/?aria.bin", "data/chr/aria.bin", false},
+		{"data/?/a.bin", "data///a.bin", false},
+		{"data/chr/[mj]*.bin", "data/chr/james.bin", true},
+		{"data/chr/[mj]*.bin", "data/chr/laura.bin", false},
+		{"data/chr/[!mj]*.bin", "data/chr/laura.bin", true},
+		{"data/chr/[!mj]*.bin", "data/chr/maria.bin", false},
+		{"data/[chr", "data/[chr", true},
+		{`data/\*.bin`, "data/*.bin", true},
+		{`data/\*.bin`, "data/a.bin", false},
+		{"data/a.b+n", "data/a.b+n", true},
+		{"data/a.b+n", "data/aXbbn", false},
+	}
+
+	for _, tt := range tests {
+		re, err := GlobToRegexp(tt.pattern)
+		if err != nil {
+			t.Errorf("GlobToRegexp(%q): %v", tt.pattern, err)
+			continue
+		}
+
+		if got := re.MatchString(tt.path); got != tt.want {
+			t.Errorf("GlobToRegexp(%q) matching %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"cmp"
+
+	"golang.org/x/exp/slices"
+)
+
+// Range is a half-open byte range [Start, End).
+type Range struct {
+	Start int64
+	End   int64
+}
+
+func (r Range) Len() int64 {
+	return r.End - r.Start
+}
+
+// SortRanges sorts ranges by their start, then by their end.
+func SortRanges(ranges []Range) {
+	slices.SortFunc(ranges, func(a, b Range) int {
+		if c := cmp.Compare(a.Start, b.Start); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.End, b.End)
+	})
+}
+
+// Gaps returns the parts of [0, size) that aren't covered by any of the ranges, in ascending order.
+// The ranges don't need to be sorted and may overlap or be empty.
+func Gaps(ranges []Range, size int64) []Range {
+	sorted := slices.Clone(ranges)
+	SortRanges(sorted)
+
+	var gaps []Range
+	var cursor int64
+	for _, r := range sorted {
+		if r.Len() <= 0 {
+			// empty chunks don't cover anything, they shouldn't split a gap in two
+			continue
+		}
+
+		if r.Start > cursor {
+			gaps = append(gaps, Range{Start: cursor, End: min(r.Start, size)})
+		}
+		cursor = max(cursor, r.End)
+
+		if cursor >= size {
+			break
+		}
+	}
+
+	if cursor < size {
+		gaps = append(gaps, Range{Start: cursor, End: size})
+	}
+
+	return Filter(gaps, func(r Range) bool {
+		return r.Len() > 0
+	})
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestSortRanges(t *testing.T) {
+	ranges := []Range{{10, 20}, {0, 5}, {10, 15}, {3, 4}}
+	SortRanges(ranges)
+
+	want := []Range{{0, 5}, {3, 4}, {10, 15}, {10, 20}}
+	if !slices.Equal(ranges, want) {
+		t.Errorf("SortRanges = %v, want %v", ranges, want)
+	}
+}
+
+func TestGaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []Range
+		size   int64
+		want   []Range
+	}{
+		{"no ranges", nil, 100, []Range{{0, 100}}},
+		{"fully covered", []Range{{0, 100}}, 100, nil},
+		{"gap at the start", []Range{{10, 100}}, 100, []Range{{0, 10}}},
+		{"gap at the end", []Range{{0, 90}}, 100, []Range{{90, 100}}},
+		{"gaps between", []Range{{0, 10}, {20, 30}, {40, 100}}, 100, []Range{{10, 20}, {30, 40}}},
+		{"unsorted", []Range{{40, 100}, {0, 10}, {20, 30}}, 100, []Range{{10, 20}, {30, 40}}},
+		{"overlapping", []Range{{0, 30}, {10, 20}, {25, 50}}, 100, []Range{{50, 100}}},
+		{"adjacent", []Range{{0, 10}, {10, 20}}, 30, []Range{{20, 30}}},
+		{"past the end", []Range{{0, 10}, {90, 120}}, 100, []Range{{10, 90}}},
+		{"starting past the end", []Range{{0, 10}, {150, 200}}, 100, []Range{{10, 100}}},
+		{"empty ranges", []Range{{5, 5}, {0, 0}}, 10, []Range{{0, 10}}},
+		{"nothing to cover", []Range{{0, 10}}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges := slices.Clone(tt.ranges)
+			got := Gaps(tt.ranges, tt.size)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Gaps(%v, %d) = %v, want %v", tt.ranges, tt.size, got, tt.want)
+			}
+
+			if !slices.Equal(tt.ranges, ranges) {
+				t.Errorf("Gaps modified its input: %v", tt.ranges)
+			}
+		})
+	}
+}
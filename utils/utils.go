@@ -7,11 +7,17 @@ import (
 	"fmt"
 	"golang.org/x/exp/constraints"
 	"io"
-	"os"
 
 	"golang.org/x/exp/slices"
 )
 
+// ReadSeekerAt is implemented by both *os.File and the files on a disc image.
+type ReadSeekerAt interface {
+	io.Reader
+	io.Seeker
+	io.ReaderAt
+}
+
 var (
 	ErrInvalidASCIIChar  = errors.New("invalid ASCII char")
 	ErrMaxLengthExceeded = errors.New("max search length exceeded")
@@ -53,13 +59,9 @@ func CurrentPos(s io.ReadSeeker) int64 {
 
 // CopyPartOfFileToFile basically does exactly what it says on the tin.
 // Useful for copying chunks from large files into new smaller files.
-func CopyPartOfFileToFile(dst, src *os.File, srcOffset, srcLength int64) error {
-	_, err := src.Seek(srcOffset, io.SeekStart)
-	if err != nil {
-		return err
-	}
-
-	_, err = io.CopyN(dst, src, srcLength)
+// The source is read with positional reads, so it's safe to copy from the same file concurrently.
+func CopyPartOfFileToFile(dst io.Writer, src io.ReaderAt, srcOffset, srcLength int64) error {
+	_, err := io.CopyN(dst, io.NewSectionReader(src, srcOffset, srcLength), srcLength)
 	if err != nil {
 		return err
 	}
@@ -68,7 +70,7 @@ func CopyPartOfFileToFile(dst, src *os.File, srcOffset, srcLength int64) error {
 }
 
 // HashFileSHA1 rewinds a file's pointer to the beginning, then returns an SHA1 hash of its contents.
-func HashFileSHA1(f *os.File) (string, error) {
+func HashFileSHA1(f io.ReadSeeker) (string, error) {
 	_, err := f.Seek(0, io.SeekStart)
 	if err != nil {
 		return "", err
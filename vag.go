@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sh2unpack/audio"
+)
+
+// decodeVAG decodes a VAGp file, or headerless SPU ADPCM if a sample rate is given.
+func decodeVAG(data []byte, sampleRate int) (*audio.WAV, error) {
+	wav, err := audio.DecodeVAG(data, sampleRate)
+	if errors.Is(err, audio.ErrNotVAG) && sampleRate != 0 {
+		return audio.DecodeSPU(data, sampleRate), nil
+	}
+
+	return wav, err
+}
+
+func (opts *VAGOptions) Execute(args []string) error {
+	for _, input := range opts.Pos.Inputs {
+		inPath := string(input)
+
+		outDir := string(opts.OutDir)
+		if outDir == "" {
+			outDir = filepath.Dir(inPath)
+		}
+
+		err := os.MkdirAll(outDir, 0700)
+		if err != nil {
+			return fmt.Errorf("Can't create output dir %s: %v", outDir, err)
+		}
+
+		data, err := os.ReadFile(inPath)
+		if err != nil {
+			return fmt.Errorf("Can't read file: %v", err)
+		}
+
+		if opts.Debug && bytes.HasPrefix(data, []byte("VAGp")) {
+			h, err := audio.ReadVAGHeader(data)
+			if err == nil {
+				fmt.Printf("%s: %+v\n", inPath, h)
+			}
+		}
+
+		wav, err := decodeVAG(data, opts.SampleRate)
+		if errors.Is(err, audio.ErrNotVAG) {
+			return fmt.Errorf("%s: %v, pass --sample-rate to decode headerless SPU ADPCM", inPath, err)
+		}
+		if err != nil {
+			return fmt.Errorf("Can't decode %s: %v", inPath, err)
+		}
+
+		wavPath := filepath.Join(outDir, trimExt(filepath.Base(inPath))+".wav")
+		err = writeWAVFile(wavPath, wav)
+		if err != nil {
+			return fmt.Errorf("Can't write %s: %v", wavPath, err)
+		}
+
+		loop := "no loop"
+		if wav.Loop != nil {
+			loop = fmt.Sprintf("loops from sample %d to %d", wav.Loop.Start, wav.Loop.End)
+		}
+
+		fmt.Printf("%s: %d samples at %d Hz, %s\n", inPath, len(wav.Samples), wav.SampleRate, loop)
+	}
+
+	return nil
+}
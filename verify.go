@@ -0,0 +1,191 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"sh2unpack/sh2"
+	"sh2unpack/utils"
+)
+
+// verifyReport collects problems by category, keeping the order categories were first seen in.
+type verifyReport struct {
+	categories []string
+	problems   map[string][]string
+}
+
+func (r *verifyReport) add(category string, format string, a ...any) {
+	if r.problems == nil {
+		r.problems = map[string][]string{}
+	}
+
+	if _, ok := r.problems[category]; !ok {
+		r.categories = append(r.categories, category)
+	}
+	r.problems[category] = append(r.problems[category], fmt.Sprintf(format, a...))
+}
+
+func (r *verifyReport) count() int {
+	n := 0
+	for _, p := range r.problems {
+		n += len(p)
+	}
+	return n
+}
+
+// verifyChunk is a data file's chunk within its mergefile.
+type verifyChunk struct {
+	utils.Range
+	path string
+}
+
+// checkUnknownFields reports MergeFileEntries with non-zero Unknown1 or Unknown2 fields.
+func checkUnknownFields(report *verifyReport, kind string, entries map[uint32]sh2.MergeFileEntry) {
+	addresses := maps.Keys(entries)
+	slices.Sort(addresses)
+
+	for _, addr := range addresses {
+		entry := entries[addr]
+		if entry.Unknown1 != 0 || entry.Unknown2 != 0 {
+			report.add("Non-zero unknown fields", "%s entry at 0x%X: %s", kind, addr, entry)
+		}
+	}
+}
+
+// checkOverlaps reports chunks that partially overlap within a mergefile.
+// Chunks with identical ranges are shared between data files, those are only counted.
+func checkOverlaps(report *verifyReport, mgfPath string, chunks []verifyChunk) int {
+	slices.SortStableFunc(chunks, func(a, b verifyChunk) int {
+		if c := cmp.Compare(a.Start, b.Start); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.End, b.End)
+	})
+
+	numShared := 0
+	furthest := 0 // index of the chunk reaching furthest into the mergefile so far
+	for i := 1; i < len(chunks); i++ {
+		cur := chunks[i]
+		if cur.Range == chunks[i-1].Range {
+			numShared++
+			continue
+		}
+
+		if chunks[furthest].End > cur.Start {
+			report.add("Overlapping chunks", "%s: %s (0x%X-0x%X) overlaps %s (0x%X-0x%X)",
+				mgfPath, cur.path, cur.Start, cur.End, chunks[furthest].path, chunks[furthest].Start, chunks[furthest].End)
+		}
+
+		if cur.End > chunks[furthest].End {
+			furthest = i
+		}
+	}
+
+	return numShared
+}
+
+func (opts *VerifyOptions) Execute(args []string) error {
+	input, err := opts.InputOptions.open(opts.Debug, false)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	input.printVersion()
+
+	dataMap := input.DataMap
+
+	report := verifyReport{}
+	mgfChunks := map[string][]verifyChunk{}
+	var mgfPaths []string
+	numDataFiles := 0
+
+	// walk the file-to-path table the same way DataMap.DataFiles does, but keep going when something's wrong
+	for _, ftp := range dataMap.FileToPathOffsets {
+		datPath, ok := dataMap.GetFilePath(ftp.PathOffset)
+		if !ok {
+			report.add("Unresolvable paths", "file-path entry %s: no path at 0x%X", ftp, ftp.PathOffset)
+			datPath = fmt.Sprintf("<0x%X>", ftp.PathOffset)
+		}
+
+		_, isBinaryFile := dataMap.GetBinaryFileEntry(ftp.FileOffset)
+		_, isMergeFile := dataMap.GetMergeFileEntry(ftp.FileOffset)
+		datEntry, isDataFile := dataMap.GetDataFileEntry(ftp.FileOffset)
+
+		if !isBinaryFile && !isMergeFile && !isDataFile {
+			report.add("Dangling file-path entries", "%s (%s): no entry at 0x%X", ftp, datPath, ftp.FileOffset)
+			continue
+		}
+
+		if !isDataFile {
+			continue
+		}
+		numDataFiles++
+
+		mgfEntry, err := dataMap.GetMergeFileEntryFromDataFileEntry(datEntry)
+		if err != nil {
+			report.add("Failed mergefile lookups", "%s %s: %v", datPath, datEntry, err)
+			continue
+		}
+
+		mgfPath, ok := dataMap.GetFilePath(mgfEntry.PathOffset)
+		if !ok {
+			report.add("Unresolvable paths", "mergefile entry %s of %s: no path at 0x%X", mgfEntry, datPath, mgfEntry.PathOffset)
+			continue
+		}
+
+		if _, ok := mgfChunks[mgfPath]; !ok {
+			mgfPaths = append(mgfPaths, mgfPath)
+		}
+
+		start := int64(datEntry.ChunkOffset)
+		mgfChunks[mgfPath] = append(mgfChunks[mgfPath], verifyChunk{
+			Range: utils.Range{Start: start, End: start + int64(datEntry.ChunkLength)},
+			path:  datPath,
+		})
+	}
+
+	numShared := 0
+	for _, mgfPath := range mgfPaths {
+		chunks := mgfChunks[mgfPath]
+
+		mgfSize, err := input.FileSize(mgfPath)
+		if err != nil {
+			report.add("Missing mergefiles", "%s: %v", mgfPath, err)
+		} else {
+			for _, chunk := range chunks {
+				if chunk.End > mgfSize {
+					report.add("Chunks past the end of their mergefile", "%s: %s ends at 0x%X, but the mergefile is only 0x%X bytes long",
+						mgfPath, chunk.path, chunk.End, mgfSize)
+				}
+			}
+		}
+
+		numShared += checkOverlaps(&report, mgfPath, chunks)
+	}
+
+	checkUnknownFields(&report, "binary file", dataMap.GetBinaryFileEntries())
+	checkUnknownFields(&report, "mergefile", dataMap.GetMergeFileEntries())
+
+	for _, category := range report.categories {
+		problems := report.problems[category]
+		fmt.Printf("%s (%d):\n", category, len(problems))
+		for _, problem := range problems {
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+
+	fmt.Printf("Checked %d file-path entries, %d data files and %d mergefiles.\n", len(dataMap.FileToPathOffsets), numDataFiles, len(mgfPaths))
+	if numShared > 0 {
+		fmt.Printf("%d data files share their chunk with another data file.\n", numShared)
+	}
+
+	if n := report.count(); n > 0 {
+		return fmt.Errorf("Found %d problems", n)
+	}
+
+	fmt.Println("No problems found.")
+	return nil
+}